@@ -2,9 +2,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log"
+	"math/rand"
 	"net/http"
 	"time"
 
@@ -42,27 +46,91 @@ type GraphQLResponse struct {
 
 var signer *v4.Signer
 
-func requestGraphql(query string, variables map[string]interface{}, responseData interface{}) error {
-	var err error
-	var response GraphQLResponse
-	client := &http.Client{}
+const GRAPHQL_TIMEOUT = 10 * time.Second
+const GRAPHQL_MAX_ATTEMPTS = 5
+const GRAPHQL_RESPONSE_LIMIT = 16 * 1024 * 1024 // bytes
+
+// GRAPHQL_RETRY_BASE_DELAY から倍々に待ち、GRAPHQL_RETRY_MAX_DELAY で頭打ちにする
+var GRAPHQL_RETRY_BASE_DELAY = 200 * time.Millisecond
+var GRAPHQL_RETRY_MAX_DELAY = 5 * time.Second
+
+var graphqlClient = &http.Client{Timeout: GRAPHQL_TIMEOUT}
+var retryRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// retryableError は時間をおいて送り直せば成功しうるエラー
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// retryDelay は attempt 回目の失敗の後に待つ時間を返す。複数のジャッジが一斉に送り直さないよう揺らぎを入れる
+func retryDelay(attempt int) time.Duration {
+	delay := GRAPHQL_RETRY_BASE_DELAY << uint(attempt)
+	if delay <= 0 || delay > GRAPHQL_RETRY_MAX_DELAY {
+		delay = GRAPHQL_RETRY_MAX_DELAY
+	}
+	return delay/2 + time.Duration(retryRand.Int63n(int64(delay/2)+1))
+}
+
+// requestGraphql は通信エラーや 5xx, 429 のときに間をおいて送り直す
+func requestGraphql(ctx context.Context, query string, variables map[string]interface{}, responseData interface{}) error {
 	request := GraphQLRequest{query, variables}
 	requestData, err := json.Marshal(&request)
 	if err != nil {
 		return err
 	}
+	for attempt := 0; ; attempt++ {
+		err = sendGraphqlRequest(ctx, requestData, responseData)
+		retryable, ok := err.(*retryableError)
+		if !ok {
+			return err
+		}
+		if attempt+1 >= GRAPHQL_MAX_ATTEMPTS {
+			return retryable.err
+		}
+		log.Printf("GraphQL request failed, retrying: %v", retryable.err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay(attempt)):
+		}
+	}
+}
+
+func sendGraphqlRequest(ctx context.Context, requestData []byte, responseData interface{}) error {
+	var response GraphQLResponse
 	req, err := http.NewRequest("POST", API_ENDPOINT, bytes.NewReader(requestData))
 	if err != nil {
 		return err
 	}
+	req = req.WithContext(ctx)
 	req.Header.Set("Content-Type", "application/json")
-	signer.Sign(req, bytes.NewReader(requestData), "appsync", AWS_REGION, time.Now())
-	res, err := client.Do(req)
+	// 署名には時刻が含まれるので、送り直すたびに署名する
+	_, err = signer.Sign(req, bytes.NewReader(requestData), "appsync", AWS_REGION, time.Now())
 	if err != nil {
 		return err
 	}
+	res, err := graphqlClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &retryableError{err}
+	}
 	defer res.Body.Close()
-	bodyData, err := ioutil.ReadAll(res.Body)
+	bodyData, err := ioutil.ReadAll(io.LimitReader(res.Body, GRAPHQL_RESPONSE_LIMIT))
+	if err != nil {
+		return &retryableError{err}
+	}
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+		return &retryableError{fmt.Errorf("GraphQL request failed with status %d: %s", res.StatusCode, bodyData)}
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GraphQL request failed with status %d: %s", res.StatusCode, bodyData)
+	}
 
 	err = json.Unmarshal(bodyData, &response)
 	if err != nil {
@@ -85,32 +153,51 @@ func requestGraphql(query string, variables map[string]interface{}, responseData
 	return nil
 }
 
+// ResponsePlaygroundInput の index は何番目の入力に対する結果かを表す。
+// コンパイルエラーのようにすべての入力に共通する結果では index, exitCode, time, memory は nil とする
 type ResponsePlaygroundInput struct {
-	SessionID string `json:"sessionID"`
-	UserID    string `json:"userID"`
-	ExitCode  int    `json:"exitCode"`
-	Time      int    `json:"time"`
-	Memory    int    `json:"memory"`
-	Stdout    string `json:"stdout"`
-	Stderr    string `json:"stderr"`
+	SessionID     string  `json:"sessionID"`
+	UserID        string  `json:"userID"`
+	Index         *int    `json:"index"`
+	Status        string  `json:"status"`
+	ExitCode      *int    `json:"exitCode"`
+	Signal        *int    `json:"signal"`
+	Time          *int    `json:"time"`
+	Memory        *int    `json:"memory"`
+	Stdout        string  `json:"stdout"`
+	Stderr        string  `json:"stderr"`
+	CompileOutput string  `json:"compileOutput"`
+	Testcase      *string `json:"testcase,omitempty"` // SAMPLE_TEST のみ
+	Verdict       *string `json:"verdict,omitempty"`  // SAMPLE_TEST のみ
+}
+
+// responsePlaygroundError はプログラムを実行できなかったことを返す
+func responsePlaygroundError(ctx context.Context, data JudgeQueueData, status string, compileOutput string) error {
+	return responsePlayground(ctx, ResponsePlaygroundInput{SessionID: data.SessionID, UserID: data.UserID, Status: status, CompileOutput: compileOutput})
 }
 
-func responsePlayground(sessionID string, userID string, exitCode int, time, memory int, stdout, stderr string) error {
+func responsePlayground(ctx context.Context, input ResponsePlaygroundInput) error {
 	variables := make(map[string]interface{})
 	query := `
 		mutation ResponsePlayground($input: ResponsePlaygroundInput!) {
 			responsePlayground(input: $input) {
 				sessionID
 				userID
+				index
+				status
 				exitCode
+				signal
 				time
 				memory
 				stdout
 				stderr
+				compileOutput
+				testcase
+				verdict
 			}
 		}
 	`
-	variables["input"] = ResponsePlaygroundInput{sessionID, userID, exitCode, time, memory, stdout, stderr}
-	err := requestGraphql(query, variables, nil)
+	variables["input"] = input
+	err := requestGraphql(ctx, query, variables, nil)
 	return err
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+func withGraphqlServer(t *testing.T, handler http.HandlerFunc) {
+	server := httptest.NewServer(handler)
+	endpoint, baseDelay, originalSigner := API_ENDPOINT, GRAPHQL_RETRY_BASE_DELAY, signer
+	API_ENDPOINT, GRAPHQL_RETRY_BASE_DELAY = server.URL, time.Millisecond
+	signer = v4.NewSigner(credentials.NewStaticCredentials("id", "secret", ""))
+	t.Cleanup(func() {
+		server.Close()
+		API_ENDPOINT, GRAPHQL_RETRY_BASE_DELAY, signer = endpoint, baseDelay, originalSigner
+	})
+}
+
+func TestRequestGraphqlRetries(t *testing.T) {
+	attempts := 0
+	withGraphqlServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"data": {"value": 42}}`)
+	})
+	var response struct {
+		Value int `json:"value"`
+	}
+	if err := requestGraphql(context.Background(), "query", nil, &response); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || response.Value != 42 {
+		t.Errorf("attempts = %d, value = %d", attempts, response.Value)
+	}
+}
+
+func TestRequestGraphqlDoesNotRetryClientErrors(t *testing.T) {
+	attempts := 0
+	withGraphqlServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusForbidden)
+	})
+	if err := requestGraphql(context.Background(), "query", nil, nil); err == nil {
+		t.Error("403 must be an error")
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d; expected 1", attempts)
+	}
+}
+
+func TestRequestGraphqlGivesUp(t *testing.T) {
+	attempts := 0
+	withGraphqlServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	if err := requestGraphql(context.Background(), "query", nil, nil); err == nil {
+		t.Error("429 must be an error after retries")
+	}
+	if attempts != GRAPHQL_MAX_ATTEMPTS {
+		t.Errorf("attempts = %d; expected %d", attempts, GRAPHQL_MAX_ATTEMPTS)
+	}
+}
@@ -1,35 +1,158 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
-func compile(definition LanguageDefinition, dir string) (compiled bool, stderr string, err error) {
+const COMPILE_PROCESS_LIMIT = 128
+const COMPILE_FILE_SIZE_LIMIT = 512 * 1024 // KB
+const COMPILE_OUTPUT_LIMIT = 64 * 1024     // bytes
+const COMPILE_WARNING_LIMIT = 16 * 1024    // bytes
+
+// COMPILE_OUT_OF_MEMORY_MESSAGES は仮想メモリの上限に達したコンパイラが出すメッセージ (小文字で比べる)
+var COMPILE_OUT_OF_MEMORY_MESSAGES = []string{
+	"virtual memory exhausted",
+	"out of memory",
+	"cannot allocate",
+	"std::bad_alloc",
+	"memory allocation of",
+	"outofmemoryerror",
+	"could not reserve enough space",
+}
+
+type CompileResultStatus int
+
+const (
+	CompileResultStatusSuccess CompileResultStatus = iota
+	CompileResultStatusCompileError
+	CompileResultStatusLimitExceeded
+)
+
+type CompileResult struct {
+	status      CompileResultStatus
+	output      string
+	diagnostics []CompileDiagnostic
+	time        int
+	memory      int
+}
+
+func (r CompileResult) compiled() bool {
+	return r.status == CompileResultStatusSuccess
+}
+
+// verdict は提出やプレイグラウンドに返すコンパイル結果の状態を返す
+func (r CompileResult) verdict() string {
+	switch r.status {
+	case CompileResultStatusCompileError:
+		return "CE"
+	case CompileResultStatusLimitExceeded:
+		return "CLE"
+	}
+	return "OK"
+}
+
+// compileRanOutOfMemory は失敗したコンパイルの出力から、仮想メモリの上限に達したかを推測する
+func compileRanOutOfMemory(output string) bool {
+	output = strings.ToLower(output)
+	for _, message := range COMPILE_OUT_OF_MEMORY_MESSAGES {
+		if strings.Contains(output, message) {
+			return true
+		}
+	}
+	return false
+}
+
+// compile は sources が与えられた場合 graderCompileCommand でそれらをまとめてコンパイルする。
+// ctx が取り消されるとコンパイラを止め、ctx のエラーを返す
+func compile(ctx context.Context, definition LanguageDefinition, dir string, sources ...string) (result CompileResult, err error) {
 	defer func() {
 		if sealErr := sealSandboxDirectory(dir); sealErr != nil && err == nil {
-			compiled = false
+			result.status = CompileResultStatusCompileError
 			err = sealErr
 		}
 	}()
 
-	if definition.CompileCommand == "" {
-		return true, "", nil
+	compileCommand := definition.CompileCommand
+	if len(sources) > 0 {
+		compileCommand = strings.ReplaceAll(definition.GraderCompileCommand, GRADER_SOURCES_PLACEHOLDER, strings.Join(sources, " "))
+	}
+	if compileCommand == "" {
+		return result, nil
+	}
+	if err := ctx.Err(); err != nil {
+		result.status = CompileResultStatusCompileError
+		return result, err
 	}
 	homeDir := filepath.Join(dir, ".home")
 	if err := createSandboxDirectory(homeDir); err != nil {
-		return false, "", err
+		return result, err
 	}
-	command := definition.CompileCommand + "; EXIT_CODE=$?; kill -SIGKILL -1; wait; exit $EXIT_CODE"
+	timeLimit := definition.compileTimeLimit()
+	memoryLimit := definition.compileMemoryLimit()
+	// コンパイルコマンドは複数の文からなることもあるので、全体を timeout の下で動かす
+	command := fmt.Sprintf("ulimit -u %d -v %d -f %d && timeout --preserve-status -sSIGKILL %d bash -c \"$COMPILE_COMMAND\"; EXIT_CODE=$?; kill -SIGKILL -1; wait; exit $EXIT_CODE", COMPILE_PROCESS_LIMIT, definition.compileAddressSpaceLimit(), COMPILE_FILE_SIZE_LIMIT, timeLimit)
 	cmd := sandboxedCommand("bash", "-c", command)
 	configureSandboxedCommand(cmd, homeDir)
+	cmd.Env = append(cmd.Env, definition.environment()...)
+	cmd.Env = append(cmd.Env, "COMPILE_COMMAND="+compileCommand)
 	cmd.Dir = dir
-	output, err := cmd.CombinedOutput()
-	if err == nil {
-		return true, "", nil
+	output := newLimitedWriter(COMPILE_OUTPUT_LIMIT)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	start := time.Now()
+	if err = cmd.Start(); err != nil {
+		result.status = CompileResultStatusCompileError
+		return result, err
+	}
+	stopKilling := killOnCancel(ctx, CHILD_UID)
+	err = cmd.Wait()
+	end := time.Now()
+	stopKilling()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		result.status = CompileResultStatusCompileError
+		return result, ctxErr
+	}
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		result.status = CompileResultStatusCompileError
+		return result, err
+	}
+	result.time = int((end.Sub(start)).Milliseconds())
+	result.memory = int(cmd.ProcessState.SysUsage().(*syscall.Rusage).Maxrss)
+	result.output = output.String()
+	result.diagnostics = parseCompileDiagnostics(result.output)
+
+	var limitMessage string
+	if result.time >= timeLimit*1000 {
+		limitMessage = fmt.Sprintf("Compilation time limit exceeded (%d s).", timeLimit)
+	} else if result.memory > memoryLimit || (err != nil && compileRanOutOfMemory(result.output)) {
+		limitMessage = fmt.Sprintf("Compilation memory limit exceeded (%d KB).", memoryLimit)
+	} else if cmd.ProcessState.ExitCode() == 128+int(syscall.SIGXFSZ) {
+		limitMessage = fmt.Sprintf("Compilation file size limit exceeded (%d KB).", COMPILE_FILE_SIZE_LIMIT)
+	}
+	if limitMessage != "" {
+		result.status = CompileResultStatusLimitExceeded
+		if result.output != "" {
+			result.output = strings.TrimRight(result.output, "\n") + "\n"
+		}
+		result.output += limitMessage
+		return result, nil
+	}
+	if err != nil {
+		result.status = CompileResultStatusCompileError
+		return result, nil
 	}
-	if _, ok := err.(*exec.ExitError); ok {
-		return false, string(output), nil
+	// 成功時の出力は警告として提出に添付されるので、小さめに切り詰める
+	if !definition.ShowCompileWarnings {
+		result.output = ""
+		result.diagnostics = nil
+	} else {
+		result.output = output.stringWithin(COMPILE_WARNING_LIMIT)
 	}
-	return false, "", err
+	return result, nil
 }
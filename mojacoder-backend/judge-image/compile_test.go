@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestCompileRanOutOfMemory(t *testing.T) {
+	cases := []struct {
+		output string
+		want   bool
+	}{
+		{"cc1plus: out of memory allocating 65536 bytes after a total of 1073741824 bytes", true},
+		{"terminate called after throwing an instance of 'std::bad_alloc'", true},
+		{"bash: xmalloc: cannot allocate 1073741824 bytes", true},
+		{"Error occurred during initialization of VM\nCould not reserve enough space for object heap", true},
+		{"main.cpp:1:1: error: 'x' does not name a type", false},
+	}
+	for _, c := range cases {
+		if got := compileRanOutOfMemory(c.output); got != c.want {
+			t.Errorf("compileRanOutOfMemory(%q) = %v; expected %v", c.output, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Definitions はジョブの処理中に差し替わらないよう、言語定義とスペシャルジャッジ言語をまとめて保持する
+type Definitions struct {
+	languages    map[string]LanguageDefinition
+	spjudgelangs map[string]SpecialJudgeLang
+}
+
+// DefinitionStore は定義ファイルを再読み込みし、検証に通ったものだけを原子的に差し替える
+type DefinitionStore struct {
+	languageFile     string
+	spjudgelangsFile string
+
+	mutex       sync.RWMutex
+	definitions Definitions
+	modTimes    [2]time.Time
+}
+
+func validateDefinitions(definitions Definitions) error {
+	if len(definitions.languages) == 0 {
+		return fmt.Errorf("no languages are defined")
+	}
+	for id, definition := range definitions.languages {
+		if definition.Name == "" || definition.Filename == "" || definition.RunCommand == "" {
+			return fmt.Errorf("%s: name, filename and runCommand are required", id)
+		}
+		if definition.TimeLimitMultiplier < 0 || definition.CompileTimeLimit < 0 || definition.CompileMemoryLimit < 0 || definition.CompileAddressSpaceAllowance < 0 || definition.AdditionalMemory < 0 {
+			return fmt.Errorf("%s: limits must not be negative", id)
+		}
+		if (definition.GraderFilename == "") != (definition.GraderCompileCommand == "") {
+			return fmt.Errorf("%s: graderFilename and graderCompileCommand must be set together", id)
+		}
+	}
+	for name, spjudgelang := range definitions.spjudgelangs {
+		if _, exist := definitions.languages[spjudgelang.Id]; !exist {
+			return fmt.Errorf("special judge lang %s: language %s not found", name, spjudgelang.Id)
+		}
+	}
+	return nil
+}
+
+func loadDefinitions(languageFile, spjudgelangsFile string) (Definitions, error) {
+	var definitions Definitions
+	var err error
+	definitions.languages, err = loadLanguageDefinition(languageFile)
+	if err != nil {
+		return definitions, err
+	}
+	definitions.spjudgelangs, err = loadSpecialJudgeLangs(spjudgelangsFile)
+	if err != nil {
+		return definitions, err
+	}
+	return definitions, validateDefinitions(definitions)
+}
+
+func newDefinitionStore(languageFile, spjudgelangsFile string) (*DefinitionStore, error) {
+	store := &DefinitionStore{languageFile: languageFile, spjudgelangsFile: spjudgelangsFile}
+	if err := store.reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *DefinitionStore) current() Definitions {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.definitions
+}
+
+func (store *DefinitionStore) fileModTimes() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, file := range []string{store.languageFile, store.spjudgelangsFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// reload は定義ファイルを読み直す。不正な内容であれば現在の定義をそのまま使い続ける
+func (store *DefinitionStore) reload() error {
+	modTimes, err := store.fileModTimes()
+	if err != nil {
+		return err
+	}
+	definitions, err := loadDefinitions(store.languageFile, store.spjudgelangsFile)
+	if err != nil {
+		return err
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.definitions = definitions
+	store.modTimes = modTimes
+	return nil
+}
+
+// reloadIfModified はファイルが更新されていれば再読み込みする。ジョブの合間に呼ぶ
+func (store *DefinitionStore) reloadIfModified() {
+	modTimes, err := store.fileModTimes()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	store.mutex.RLock()
+	modified := modTimes != store.modTimes
+	store.mutex.RUnlock()
+	if !modified {
+		return
+	}
+	if err := store.reload(); err != nil {
+		log.Printf("Failed to reload definitions, keeping the current ones: %v", err)
+		// 壊れたファイルを毎回読み直さないよう、更新時刻だけは記録しておく
+		store.mutex.Lock()
+		store.modTimes = modTimes
+		store.mutex.Unlock()
+		return
+	}
+	log.Println("Reloaded definitions.")
+}
+
+// watchReloadSignal は SIGHUP を受け取ると定義を読み直す
+func (store *DefinitionStore) watchReloadSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			if err := store.reload(); err != nil {
+				log.Printf("Failed to reload definitions, keeping the current ones: %v", err)
+				continue
+			}
+			log.Println("Reloaded definitions.")
+		}
+	}()
+}
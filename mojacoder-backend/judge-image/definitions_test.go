@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidateDefinitions(t *testing.T) {
+	definitions, err := loadDefinitions("./language-definition.json", "./special-judge-langs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := Definitions{
+		languages:    map[string]LanguageDefinition{"a": {Name: "A", Filename: "a", RunCommand: "./a"}},
+		spjudgelangs: map[string]SpecialJudgeLang{"b": {Id: "b"}},
+	}
+	if validateDefinitions(invalid) == nil {
+		t.Error("unknown special judge language must be rejected")
+	}
+	invalid.spjudgelangs = definitions.spjudgelangs
+	invalid.languages = map[string]LanguageDefinition{}
+	if validateDefinitions(invalid) == nil {
+		t.Error("empty definitions must be rejected")
+	}
+}
+
+func TestDefinitionStoreRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mojacoder-definitions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	languageFile := filepath.Join(dir, "language-definition.json")
+	spjudgelangsFile := filepath.Join(dir, "special-judge-langs.json")
+	writeFile := func(path, content string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	writeFile(languageFile, `{"a": {"name": "A", "filename": "a", "runCommand": "./a"}}`, now)
+	writeFile(spjudgelangsFile, `{"A": {"id": "a"}}`, now)
+
+	store, err := newDefinitionStore(languageFile, spjudgelangsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(languageFile, `{"a": {"name": "A"`, now.Add(time.Second))
+	store.reloadIfModified()
+	if _, exist := store.current().languages["a"]; !exist {
+		t.Error("invalid definitions must not replace the current ones")
+	}
+
+	writeFile(languageFile, `{"a": {"name": "A", "filename": "a", "runCommand": "./a"}, "b": {"name": "B", "filename": "b", "runCommand": "./b"}}`, now.Add(2*time.Second))
+	store.reloadIfModified()
+	if _, exist := store.current().languages["b"]; !exist {
+		t.Error("valid definitions must be reloaded")
+	}
+}
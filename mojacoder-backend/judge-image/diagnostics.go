@@ -0,0 +1,104 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const MAX_COMPILE_DIAGNOSTICS = 100
+
+type CompileDiagnosticSeverity string
+
+const (
+	CompileDiagnosticSeverityError   CompileDiagnosticSeverity = "ERROR"
+	CompileDiagnosticSeverityWarning CompileDiagnosticSeverity = "WARNING"
+	CompileDiagnosticSeverityNote    CompileDiagnosticSeverity = "NOTE"
+)
+
+// CompileDiagnostic の line, column は 1 始まりで、不明な場合は 0 (GraphQL では null) とする
+type CompileDiagnostic struct {
+	Severity CompileDiagnosticSeverity `json:"severity"`
+	Line     int                       `json:"line,omitempty"`
+	Column   int                       `json:"column,omitempty"`
+	Message  string                    `json:"message"`
+}
+
+var (
+	// gcc, g++, kotlinc: main.cpp:3:5: error: message
+	lineColumnSeverityPattern = regexp.MustCompile(`^\S+?:(\d+):(\d+): (fatal error|error|warning|note): (.*)$`)
+	// javac: Main.java:3: error: message
+	lineSeverityPattern = regexp.MustCompile(`^\S+\.java:(\d+): (error|warning): (.*)$`)
+	// go: ./main.go:3:5: message
+	goPattern = regexp.MustCompile(`^\S+\.go:(\d+):(\d+): (.*)$`)
+	// kotlinc (旧形式): e: main.kt: (3, 5): message
+	kotlinPattern = regexp.MustCompile(`^([ew]): \S+: \((\d+), (\d+)\): (.*)$`)
+	// rustc: error[E0425]: message の後に --> main.rs:3:5 が続く
+	rustHeaderPattern   = regexp.MustCompile(`^(error|warning)(\[\w+\])?: (.*)$`)
+	rustLocationPattern = regexp.MustCompile(`^\s*--> \S+?:(\d+):(\d+)$`)
+	// python: File "main.py", line 3 の後に SyntaxError: message が続く
+	pythonLocationPattern = regexp.MustCompile(`^\s*File "[^"]*", line (\d+)`)
+	pythonErrorPattern    = regexp.MustCompile(`^(\w+(Error|Exception|Warning)): (.*)$`)
+	// rustc が最後に出す件数の要約は診断として扱わない
+	rustSummaryPattern = regexp.MustCompile(`^(aborting due to|\d+ warnings? emitted|.* generated \d+ warnings?)`)
+)
+
+func diagnosticSeverity(severity string) CompileDiagnosticSeverity {
+	switch severity {
+	case "warning", "w":
+		return CompileDiagnosticSeverityWarning
+	case "note":
+		return CompileDiagnosticSeverityNote
+	}
+	return CompileDiagnosticSeverityError
+}
+
+// parseCompileDiagnostics は主要なコンパイラの出力から行・列つきの診断を取り出す
+func parseCompileDiagnostics(output string) []CompileDiagnostic {
+	diagnostics := []CompileDiagnostic{}
+	// rustc の見出し行で、位置がまだ決まっていないもの
+	pendingRust := -1
+	pythonLine := 0
+	for _, line := range strings.Split(output, "\n") {
+		if len(diagnostics) >= MAX_COMPILE_DIAGNOSTICS {
+			break
+		}
+		line = strings.TrimRight(line, "\r")
+		if match := lineColumnSeverityPattern.FindStringSubmatch(line); match != nil {
+			lineNumber, _ := strconv.Atoi(match[1])
+			column, _ := strconv.Atoi(match[2])
+			diagnostics = append(diagnostics, CompileDiagnostic{diagnosticSeverity(match[3]), lineNumber, column, match[4]})
+		} else if match := lineSeverityPattern.FindStringSubmatch(line); match != nil {
+			lineNumber, _ := strconv.Atoi(match[1])
+			diagnostics = append(diagnostics, CompileDiagnostic{diagnosticSeverity(match[2]), lineNumber, 0, match[3]})
+		} else if match := goPattern.FindStringSubmatch(line); match != nil {
+			lineNumber, _ := strconv.Atoi(match[1])
+			column, _ := strconv.Atoi(match[2])
+			diagnostics = append(diagnostics, CompileDiagnostic{CompileDiagnosticSeverityError, lineNumber, column, match[3]})
+		} else if match := kotlinPattern.FindStringSubmatch(line); match != nil {
+			lineNumber, _ := strconv.Atoi(match[2])
+			column, _ := strconv.Atoi(match[3])
+			diagnostics = append(diagnostics, CompileDiagnostic{diagnosticSeverity(match[1]), lineNumber, column, match[4]})
+		} else if match := rustHeaderPattern.FindStringSubmatch(line); match != nil {
+			if rustSummaryPattern.MatchString(match[3]) {
+				continue
+			}
+			diagnostics = append(diagnostics, CompileDiagnostic{diagnosticSeverity(match[1]), 0, 0, match[3]})
+			pendingRust = len(diagnostics) - 1
+		} else if match := rustLocationPattern.FindStringSubmatch(line); match != nil && pendingRust >= 0 {
+			diagnostics[pendingRust].Line, _ = strconv.Atoi(match[1])
+			diagnostics[pendingRust].Column, _ = strconv.Atoi(match[2])
+			pendingRust = -1
+		} else if match := pythonLocationPattern.FindStringSubmatch(line); match != nil {
+			pythonLine, _ = strconv.Atoi(match[1])
+		} else if match := pythonErrorPattern.FindStringSubmatch(line); match != nil {
+			severity := CompileDiagnosticSeverityError
+			if strings.HasSuffix(match[1], "Warning") {
+				severity = CompileDiagnosticSeverityWarning
+			}
+			diagnostics = append(diagnostics, CompileDiagnostic{severity, pythonLine, 0, match[1] + ": " + match[3]})
+			pythonLine = 0
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCompileDiagnostics(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected []CompileDiagnostic
+	}{
+		{
+			name: "gcc",
+			output: "main.cpp: In function 'int main()':\n" +
+				"main.cpp:4:9: warning: unused variable 'x' [-Wunused-variable]\n" +
+				"    4 |     int x;\n" +
+				"main.cpp:5:5: error: 'y' was not declared in this scope\n",
+			expected: []CompileDiagnostic{
+				{CompileDiagnosticSeverityWarning, 4, 9, "unused variable 'x' [-Wunused-variable]"},
+				{CompileDiagnosticSeverityError, 5, 5, "'y' was not declared in this scope"},
+			},
+		},
+		{
+			name: "rustc",
+			output: "error[E0425]: cannot find value `y` in this scope\n" +
+				" --> main.rs:3:20\n" +
+				"  |\n" +
+				"error: aborting due to 1 previous error\n",
+			expected: []CompileDiagnostic{
+				{CompileDiagnosticSeverityError, 3, 20, "cannot find value `y` in this scope"},
+			},
+		},
+		{
+			name:   "javac",
+			output: "Main.java:3: error: cannot find symbol\n        y++;\n        ^\n1 error\n",
+			expected: []CompileDiagnostic{
+				{CompileDiagnosticSeverityError, 3, 0, "cannot find symbol"},
+			},
+		},
+		{
+			name:   "go",
+			output: "# command-line-arguments\n./main.go:6:2: undefined: y\n",
+			expected: []CompileDiagnostic{
+				{CompileDiagnosticSeverityError, 6, 2, "undefined: y"},
+			},
+		},
+		{
+			name:   "kotlinc",
+			output: "main.kt:2:13: error: unresolved reference: y\nw: main.kt: (1, 10): Parameter 'args' is never used\n",
+			expected: []CompileDiagnostic{
+				{CompileDiagnosticSeverityError, 2, 13, "unresolved reference: y"},
+				{CompileDiagnosticSeverityWarning, 1, 10, "Parameter 'args' is never used"},
+			},
+		},
+		{
+			name:   "python",
+			output: "  File \"main.py\", line 1\n    print(\n         ^\nSyntaxError: '(' was never closed\n",
+			expected: []CompileDiagnostic{
+				{CompileDiagnosticSeverityError, 1, 0, "SyntaxError: '(' was never closed"},
+			},
+		},
+		{
+			name:     "unknown",
+			output:   "something went wrong\n",
+			expected: []CompileDiagnostic{},
+		},
+	}
+	for _, test := range tests {
+		diagnostics := parseCompileDiagnostics(test.output)
+		if !reflect.DeepEqual(diagnostics, test.expected) {
+			t.Errorf("%s: parseCompileDiagnostics() = %v; expected %v", test.name, diagnostics, test.expected)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const GRADER_ZIP_PATH = "/tmp/mojacoder-grader.zip"
+
+func graderKey(problemID, lang string) string {
+	return problemID + "/graders/" + lang + ".zip"
+}
+
+// prepareGrader は提出言語用の採点プログラムを作業ディレクトリに展開する。
+// その言語の採点プログラムが用意されていなければ false を返す。
+func prepareGrader(ctx context.Context, problemID, lang string, definition LanguageDefinition) (bool, error) {
+	const errorMessage = "failed to prepare a grader: %v"
+	if definition.GraderFilename == "" {
+		return false, nil
+	}
+	key := graderKey(problemID, lang)
+	exist, err := existsInStorage(ctx, JUDGECODES_BUCKET_NAME, key)
+	if err != nil {
+		return false, fmt.Errorf(errorMessage, err)
+	}
+	if !exist {
+		return false, nil
+	}
+	if err = downloadFromStorage(ctx, GRADER_ZIP_PATH, JUDGECODES_BUCKET_NAME, key); err != nil {
+		return false, fmt.Errorf(errorMessage, err)
+	}
+	defer os.Remove(GRADER_ZIP_PATH)
+	if err = unzip(GRADER_ZIP_PATH, TEMP_DIR); err != nil {
+		return false, fmt.Errorf(errorMessage, err)
+	}
+	if _, err = os.Stat(filepath.Join(TEMP_DIR, definition.GraderFilename)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf(errorMessage, err)
+	}
+	return true, nil
+}
@@ -1,17 +1,79 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 )
 
+type LanguageInfo struct {
+	ID                  string  `json:"id"`
+	Name                string  `json:"name"`
+	Version             string  `json:"version"`
+	CompileCommand      string  `json:"compileCommand"`
+	RunCommand          string  `json:"runCommand"`
+	CompileTimeLimit    int     `json:"compileTimeLimit"`
+	CompileMemoryLimit  int     `json:"compileMemoryLimit"`
+	TimeLimitMultiplier float64 `json:"timeLimitMultiplier"`
+	AdditionalMemory    int     `json:"additionalMemory"`
+	SupportsGrader      bool    `json:"supportsGrader"`
+}
+
+type SpecialJudgeLanguageInfo struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+func languageInfos(definitions map[string]LanguageDefinition) []LanguageInfo {
+	infos := make([]LanguageInfo, 0, len(definitions))
+	for id, definition := range definitions {
+		infos = append(infos, LanguageInfo{
+			ID:                  id,
+			Name:                definition.Name,
+			Version:             definition.Version,
+			CompileCommand:      definition.CompileCommand,
+			RunCommand:          definition.RunCommand,
+			CompileTimeLimit:    definition.compileTimeLimit(),
+			CompileMemoryLimit:  definition.compileMemoryLimit(),
+			TimeLimitMultiplier: definition.timeLimitMultiplier(),
+			AdditionalMemory:    definition.AdditionalMemory,
+			SupportsGrader:      definition.GraderFilename != "",
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+func specialJudgeLanguageInfos(spjudgelangs map[string]SpecialJudgeLang) []SpecialJudgeLanguageInfo {
+	infos := make([]SpecialJudgeLanguageInfo, 0, len(spjudgelangs))
+	for name, spjudgelang := range spjudgelangs {
+		infos = append(infos, SpecialJudgeLanguageInfo{Name: name, ID: spjudgelang.Id})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
+
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "alive")
 }
 
-func health() {
+func health(store *DefinitionStore) {
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/languages", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, languageInfos(store.current().languages))
+	})
+	http.HandleFunc("/special-judge-languages", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, specialJudgeLanguageInfos(store.current().spjudgelangs))
+	})
 	go func() {
 		err := http.ListenAndServe(":3000", nil)
 		if err != nil {
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLanguageInfos(t *testing.T) {
+	definitions := map[string]LanguageDefinition{
+		"b": {Name: "B", RunCommand: "./b"},
+		"a": {Name: "A", RunCommand: "./a", TimeLimitMultiplier: 2, GraderFilename: "grader.a"},
+	}
+	infos := languageInfos(definitions)
+	if len(infos) != 2 || infos[0].ID != "a" || infos[1].ID != "b" {
+		t.Fatalf("languageInfos must be sorted by id: %v", infos)
+	}
+	if infos[0].TimeLimitMultiplier != 2 || !infos[0].SupportsGrader {
+		t.Errorf("unexpected info: %v", infos[0])
+	}
+	if infos[1].TimeLimitMultiplier != 1 || infos[1].CompileTimeLimit != DEFAULT_COMPILE_TIME_LIMIT || infos[1].SupportsGrader {
+		t.Errorf("defaults are not applied: %v", infos[1])
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// インタラクタは提出プログラムの kill -1 に巻き込まれないよう別ユーザーで動かす
+const INTERACTOR_UID = 401
+
+type InteractiveJudge struct {
+	lang                 LanguageDefinition
+	wrongAnswerExitCodes []int
+}
+
+func (i InteractiveJudge) isJudgeType() {}
+
+// runInteractiveJudge は提出プログラムとインタラクタを同時に起動し、互いの標準入出力をつなぐ。
+// インタラクタにはテストケースの入力と出力をファイルディスクリプタ経由で渡す。
+func (i InteractiveJudge) runInteractiveJudge(ctx context.Context, definition LanguageDefinition, config RunConfig, interactorStderr io.Writer, inFile, outFile *os.File) (RunResult, RunResult, error) {
+	const errorMessage = "failed to run an interactive judge: %v"
+	var contestantResult, interactorResult RunResult
+	toInteractorReader, toInteractorWriter, err := os.Pipe()
+	if err != nil {
+		return contestantResult, interactorResult, fmt.Errorf(errorMessage, err)
+	}
+	toContestantReader, toContestantWriter, err := os.Pipe()
+	if err != nil {
+		toInteractorReader.Close()
+		toInteractorWriter.Close()
+		return contestantResult, interactorResult, fmt.Errorf(errorMessage, err)
+	}
+
+	config.stdin = toContestantReader
+	config.stdout = toInteractorWriter
+	config.closeAfterStart = []io.Closer{toContestantReader, toInteractorWriter}
+	interactorConfig := RunConfig{
+		stdin:           toInteractorReader,
+		stdout:          toContestantWriter,
+		stderr:          interactorStderr,
+		timeLimit:       config.timeLimit + 1,
+		memoryLimit:     1024 * 1024,
+		dir:             SPECIAL_JUDGE_DIR,
+		runCommandArgs:  []string{"/dev/fd/3", "/dev/fd/4"},
+		extraFiles:      []*os.File{inFile, outFile},
+		closeAfterStart: []io.Closer{toInteractorReader, toContestantWriter},
+		uid:             INTERACTOR_UID,
+	}
+
+	var interactorErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		interactorResult, interactorErr = run(ctx, i.lang, interactorConfig)
+	}()
+	contestantResult, err = run(ctx, definition, config)
+	wg.Wait()
+	if err != nil {
+		return contestantResult, interactorResult, fmt.Errorf(errorMessage, err)
+	}
+	if interactorErr != nil {
+		return contestantResult, interactorResult, fmt.Errorf(errorMessage, interactorErr)
+	}
+	return contestantResult, interactorResult, nil
+}
+
+// interactiveJudgeStatus は提出プログラムの TLE/MLE を優先し、次にインタラクタの判定を採用する。
+// インタラクタが先に WA で終了すると提出プログラムは SIGPIPE で落ちるため、RE はその後に見る。
+func interactiveJudgeStatus(contestant, interactor RunResult, wrongAnswerExitCodes []int) string {
+	switch contestant.status {
+	case RunResultStatusTimeLimitExceeded:
+		return "TLE"
+	case RunResultStatusMemoryLimitExceeded:
+		return "MLE"
+	}
+	if status := specialJudgeStatus(interactor, wrongAnswerExitCodes); status != "AC" {
+		return status
+	}
+	if contestant.status == RunResultStatusRunTimeError {
+		return "RE"
+	}
+	return "AC"
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestInteractiveJudgeStatus(t *testing.T) {
+	success := RunResult{status: RunResultStatusSuccess}
+	wrongAnswer := RunResult{status: RunResultStatusRunTimeError, exitCode: 3}
+	crashed := RunResult{status: RunResultStatusRunTimeError, signal: 11}
+	cases := []struct {
+		contestant, interactor RunResult
+		want                   string
+	}{
+		{success, success, "AC"},
+		{success, wrongAnswer, "WA"},
+		{crashed, wrongAnswer, "WA"},
+		{crashed, success, "RE"},
+		{success, crashed, "JRE"},
+		{RunResult{status: RunResultStatusTimeLimitExceeded}, wrongAnswer, "TLE"},
+		{RunResult{status: RunResultStatusMemoryLimitExceeded}, success, "MLE"},
+		{success, RunResult{status: RunResultStatusTimeLimitExceeded}, "JTLE"},
+	}
+	for _, c := range cases {
+		if got := interactiveJudgeStatus(c.contestant, c.interactor, nil); got != c.want {
+			t.Errorf("interactiveJudgeStatus(%+v, %+v) = %s; expected %s", c.contestant, c.interactor, got, c.want)
+		}
+	}
+}
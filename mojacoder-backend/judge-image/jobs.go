@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const DEFAULT_JOB_TIMEOUT = 30 * time.Minute
+
+// JOB_CANCEL_CHECK_INTERVAL ごとに、ジョブの問題が削除されたり提出が再ジャッジされたりしていないかを確かめる
+const JOB_CANCEL_CHECK_INTERVAL = 5 * time.Second
+
+// JOB_TIMEOUT (例: 10m) を超えたジョブは打ち切る
+var JOB_TIMEOUT = parseJobTimeout(os.Getenv("JOB_TIMEOUT"))
+
+var PROBLEM_TABLE_NAME = os.Getenv("PROBLEM_TABLE_NAME")
+
+func parseJobTimeout(value string) time.Duration {
+	if value == "" {
+		return DEFAULT_JOB_TIMEOUT
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		log.Printf("Invalid JOB_TIMEOUT '%s', using %v", value, DEFAULT_JOB_TIMEOUT)
+		return DEFAULT_JOB_TIMEOUT
+	}
+	return timeout
+}
+
+// jobID は提出や再ジャッジなら提出 ID、問題全体の再ジャッジや問題の検証、テストケースの生成なら問題 ID、プレイグラウンドやサンプルテストならセッション ID を返す
+func jobID(data JudgeQueueData) string {
+	switch data.Type {
+	case "SUBMISSION":
+		return data.SubmissionID
+	case "REJUDGE":
+		if data.SubmissionID == "" {
+			return data.ProblemID
+		}
+		return data.SubmissionID
+	case "VALIDATE_PROBLEM", "GENERATE_TESTCASES":
+		return data.ProblemID
+	}
+	return data.SessionID
+}
+
+// JobState はジョブを続けるかどうかを決める問題と提出の状態。
+// 再ジャッジの依頼は提出の judgeGeneration を増やすので、ジョブの開始時から変わっていれば取り消す
+type JobState struct {
+	problemDeleted    bool
+	submissionDeleted bool
+	judgeGeneration   int
+}
+
+// jobCancelReason は開始時の状態 initial から current に変わったときに、ジョブを取り消す理由を返す
+func jobCancelReason(initial, current JobState) string {
+	switch {
+	case current.problemDeleted:
+		return "the problem was deleted"
+	case current.submissionDeleted:
+		return "the submission was deleted"
+	case current.judgeGeneration != initial.judgeGeneration:
+		return "the submission was rejudged"
+	}
+	return ""
+}
+
+// readJobState は DynamoDB からジョブの問題と提出の状態を読む
+func readJobState(ctx context.Context, data JudgeQueueData) (JobState, error) {
+	const errorMessage = "failed to read the state of job %s: %v"
+	var state JobState
+	if data.ProblemID != "" {
+		res, err := database.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName:                aws.String(PROBLEM_TABLE_NAME),
+			Key:                      map[string]*dynamodb.AttributeValue{"id": {S: aws.String(data.ProblemID)}},
+			ProjectionExpression:     aws.String("id, #status"),
+			ExpressionAttributeNames: map[string]*string{"#status": aws.String("status")},
+		})
+		if err != nil {
+			return state, fmt.Errorf(errorMessage, jobID(data), err)
+		}
+		status := res.Item["status"]
+		state.problemDeleted = res.Item["id"] == nil || (status != nil && aws.StringValue(status.S) == "DELETED")
+	}
+	if data.SubmissionID != "" {
+		res, err := database.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName:            aws.String(SUBMISSION_TABLE_NAME),
+			Key:                  map[string]*dynamodb.AttributeValue{"id": {S: aws.String(data.SubmissionID)}},
+			ProjectionExpression: aws.String("id, judgeGeneration"),
+			ConsistentRead:       aws.Bool(true),
+		})
+		if err != nil {
+			return state, fmt.Errorf(errorMessage, jobID(data), err)
+		}
+		state.submissionDeleted = res.Item["id"] == nil
+		if generation := res.Item["judgeGeneration"]; generation != nil {
+			state.judgeGeneration, _ = strconv.Atoi(aws.StringValue(generation.N))
+		}
+	}
+	return state, nil
+}
+
+// JobCanceller は実行中のジョブを覚えておき、問題や提出の状態が変わったら取り消す。
+// readState が nil なら状態は確かめない
+type JobCanceller struct {
+	mutex     sync.Mutex
+	id        string
+	cancel    context.CancelFunc
+	reason    string
+	readState func(ctx context.Context, data JudgeQueueData) (JobState, error)
+	interval  time.Duration
+}
+
+func newJobCanceller() *JobCanceller {
+	return &JobCanceller{readState: readJobState, interval: JOB_CANCEL_CHECK_INTERVAL}
+}
+
+// start は timeout で打ち切られるジョブ用の context を返す。ジョブが終わったら返された関数を呼ぶ
+func (c *JobCanceller) start(ctx context.Context, data JudgeQueueData, timeout time.Duration) (context.Context, func()) {
+	jobCtx, cancel := context.WithTimeout(ctx, timeout)
+	c.mutex.Lock()
+	c.id = jobID(data)
+	c.cancel = cancel
+	c.reason = ""
+	c.mutex.Unlock()
+	stopWatching := c.watch(jobCtx, data)
+	return jobCtx, func() {
+		stopWatching()
+		c.mutex.Lock()
+		c.id = ""
+		c.cancel = nil
+		c.mutex.Unlock()
+		cancel()
+	}
+}
+
+// watch はジョブの間 interval ごとに状態を読み、取り消す理由があればジョブを取り消す。返された関数を呼ぶと見張りをやめる
+func (c *JobCanceller) watch(ctx context.Context, data JudgeQueueData) func() {
+	if c.readState == nil || data.ProblemID == "" && data.SubmissionID == "" {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		var initial *JobState
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			state, err := c.readState(ctx, data)
+			if err != nil {
+				log.Println(err)
+			} else {
+				if initial == nil {
+					initial = &state
+				}
+				if reason := jobCancelReason(*initial, state); reason != "" {
+					c.cancelJob(jobID(data), reason)
+					return
+				}
+			}
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// cancelJob は id のジョブを実行中なら reason を記録して取り消し、true を返す
+func (c *JobCanceller) cancelJob(id string, reason string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if id == "" || c.cancel == nil || c.id != id {
+		return false
+	}
+	log.Printf("Canceling job %s: %s", id, reason)
+	c.reason = reason
+	c.cancel()
+	return true
+}
+
+// cancelReason は実行中か最後に終わったジョブを取り消した理由を返す。取り消していなければ空文字列を返す
+func (c *JobCanceller) cancelReason() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.reason
+}
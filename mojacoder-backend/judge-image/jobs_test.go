@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseJobTimeout(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":      DEFAULT_JOB_TIMEOUT,
+		"10m":   10 * time.Minute,
+		"-1s":   DEFAULT_JOB_TIMEOUT,
+		"three": DEFAULT_JOB_TIMEOUT,
+	}
+	for value, want := range tests {
+		if got := parseJobTimeout(value); got != want {
+			t.Errorf("parseJobTimeout(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestJobCancellerCancelsOnlyRunningJob(t *testing.T) {
+	var jobs JobCanceller
+	ctx, finish := jobs.start(context.Background(), JudgeQueueData{Type: "SUBMISSION", SubmissionID: "s1", SessionID: "x"}, time.Minute)
+	if jobs.cancelJob("x", "test") || jobs.cancelJob("", "test") {
+		t.Fatal("canceled a job with an unrelated id")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("job was canceled unexpectedly")
+	}
+	if !jobs.cancelJob("s1", "test") {
+		t.Fatal("failed to cancel the running job")
+	}
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("job context error = %v, want %v", ctx.Err(), context.Canceled)
+	}
+	finish()
+	if jobs.cancelReason() != "test" {
+		t.Errorf("cancelReason() = %q", jobs.cancelReason())
+	}
+	if jobs.cancelJob("s1", "test") {
+		t.Fatal("canceled a finished job")
+	}
+}
+
+func TestJobCancellerTimeout(t *testing.T) {
+	var jobs JobCanceller
+	ctx, finish := jobs.start(context.Background(), JudgeQueueData{Type: "PLAYGROUND", SessionID: "p1"}, time.Millisecond)
+	defer finish()
+	<-ctx.Done()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatalf("job context error = %v, want %v", ctx.Err(), context.DeadlineExceeded)
+	}
+}
+
+func TestJobCancelReason(t *testing.T) {
+	initial := JobState{judgeGeneration: 1}
+	tests := []struct {
+		current JobState
+		want    string
+	}{
+		{JobState{judgeGeneration: 1}, ""},
+		{JobState{judgeGeneration: 2}, "the submission was rejudged"},
+		{JobState{judgeGeneration: 1, submissionDeleted: true}, "the submission was deleted"},
+		{JobState{judgeGeneration: 1, problemDeleted: true}, "the problem was deleted"},
+	}
+	for _, test := range tests {
+		if got := jobCancelReason(initial, test.current); got != test.want {
+			t.Errorf("jobCancelReason(%+v) = %q, want %q", test.current, got, test.want)
+		}
+	}
+}
+
+func TestJobCancellerCancelsRejudgedSubmission(t *testing.T) {
+	var mutex sync.Mutex
+	generation := 0
+	jobs := &JobCanceller{
+		readState: func(ctx context.Context, data JudgeQueueData) (JobState, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return JobState{judgeGeneration: generation}, nil
+		},
+		interval: time.Millisecond,
+	}
+	ctx, finish := jobs.start(context.Background(), JudgeQueueData{Type: "SUBMISSION", SubmissionID: "s1", ProblemID: "p1"}, time.Minute)
+	defer finish()
+	time.Sleep(20 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatal("job was canceled without any change")
+	}
+	mutex.Lock()
+	generation++
+	mutex.Unlock()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("rejudged submission was not canceled")
+	}
+	if jobs.cancelReason() != "the submission was rejudged" {
+		t.Errorf("cancelReason() = %q", jobs.cancelReason())
+	}
+}
+
+func TestJobCancellerDoesNotWatchPlayground(t *testing.T) {
+	jobs := &JobCanceller{
+		readState: func(ctx context.Context, data JudgeQueueData) (JobState, error) {
+			return JobState{problemDeleted: true}, nil
+		},
+		interval: time.Millisecond,
+	}
+	ctx, finish := jobs.start(context.Background(), JudgeQueueData{Type: "PLAYGROUND", SessionID: "x"}, time.Minute)
+	defer finish()
+	time.Sleep(20 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatal("playground job was canceled")
+	}
+}
@@ -1,30 +1,40 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"math/big"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
 const PRECISION = 128
 
 type TestcaseResultInput struct {
-	Name   string `json:"name"`
-	Status string `json:"status"`
-	Time   int    `json:"time"`
-	Memory int    `json:"memory"`
+	Name   string  `json:"name"`
+	Status string  `json:"status"`
+	Time   int     `json:"time"`
+	Memory int     `json:"memory"`
+	Detail *string `json:"detail,omitempty"` // RE の理由 (SIGSEGV, exit 1 など)
 }
 
 type UpdateSubmissionStatusInput struct {
-	ID        string                 `json:"id"`
-	UserID    string                 `json:"userID"`
-	Status    string                 `json:"status"`
-	Stderr    *string                `json:"stderr"`
-	Testcases *[]TestcaseResultInput `json:"testcases"`
+	ID          string                 `json:"id"`
+	UserID      string                 `json:"userID"`
+	Status      string                 `json:"status"`
+	Stderr      *string                `json:"stderr"`
+	Testcases   *[]TestcaseResultInput `json:"testcases"`
+	JudgeLog    *string                `json:"judgeLog"`
+	Diagnostics *[]CompileDiagnostic   `json:"diagnostics,omitempty"`
+	*SubmissionSummary
 }
 
 type JudgeType interface {
@@ -32,54 +42,158 @@ type JudgeType interface {
 }
 
 type SpecialJudge struct {
-	lang LanguageDefinition
+	lang                 LanguageDefinition
+	wrongAnswerExitCodes []int
 }
 
 func (s SpecialJudge) isJudgeType() {}
 
 type ProblemResponse struct {
 	Problem struct {
-		JudgeType string `json:"judgeType"`
-		JudgeLang string `json:"judgeLang"`
+		JudgeType       string    `json:"judgeType"`
+		JudgeLang       string    `json:"judgeLang"`
+		ProblemType     string    `json:"problemType"`
+		JudgePolicy     string    `json:"judgePolicy"`
+		Subtasks        []Subtask `json:"subtasks"`
+		Samples         []string  `json:"samples"`
+		TestcasesStatus string    `json:"testcasesStatus"`
+		ValidatorLang   string    `json:"validatorLang"`
 	} `json:"problem"`
 }
 
-func getJudgeType(problemID string, spjudgelangs map[string]SpecialJudgeLang, definitions map[string]LanguageDefinition) (JudgeType, error) {
+const (
+	PROBLEM_TYPE_NORMAL      = "NORMAL"
+	PROBLEM_TYPE_OUTPUT_ONLY = "OUTPUT_ONLY"
+	PROBLEM_TYPE_GRADER      = "GRADER"
+)
+
+// errTestcasesNotReady はテストケースの生成が終わっておらず、まだ判定できないことを表す
+var errTestcasesNotReady = errors.New("testcases are being generated")
+
+type ProblemSetting struct {
+	judgeType      JudgeType
+	problemType    string
+	judgePolicy    string
+	subtasks       []Subtask
+	samples        []string
+	validatorLang  string
+	testcasesReady bool
+}
+
+func getProblemSetting(ctx context.Context, problemID string, spjudgelangs map[string]SpecialJudgeLang, definitions map[string]LanguageDefinition) (ProblemSetting, error) {
 	query := `
-		query GetJudgeType($problemID: ID!) {
+		query GetProblemSetting($problemID: ID!) {
 			problem(id: $problemID) {
 				judgeType
 				judgeLang
+				problemType
+				judgePolicy
+				subtasks {
+					name
+					testcases
+				}
+				samples
+				testcasesStatus
+				validatorLang
 			}
 		}
 	`
+	var setting ProblemSetting
 	var responseData ProblemResponse
 	variables := make(map[string]interface{})
 	variables["problemID"] = problemID
-	err := requestGraphql(query, variables, &responseData)
-	log.Println("responsData: &s", responseData)
+	err := requestGraphql(ctx, query, variables, &responseData)
+	log.Printf("responsData: %v", responseData)
+	if err != nil {
+		return setting, err
+	}
+	setting.judgeType, err = getJudgeType(responseData.Problem.JudgeType, responseData.Problem.JudgeLang, spjudgelangs, definitions)
+	if err != nil {
+		return setting, err
+	}
+	switch responseData.Problem.ProblemType {
+	case "", PROBLEM_TYPE_NORMAL:
+		setting.problemType = PROBLEM_TYPE_NORMAL
+	case PROBLEM_TYPE_OUTPUT_ONLY:
+		if _, ok := setting.judgeType.(InteractiveJudge); ok {
+			return setting, fmt.Errorf("output only problem can't be interactive")
+		}
+		setting.problemType = responseData.Problem.ProblemType
+	case PROBLEM_TYPE_GRADER:
+		setting.problemType = responseData.Problem.ProblemType
+	default:
+		return setting, fmt.Errorf("unknown problemType '%s'", responseData.Problem.ProblemType)
+	}
+	setting.subtasks = responseData.Problem.Subtasks
+	setting.samples = responseData.Problem.Samples
+	setting.testcasesReady = responseData.Problem.TestcasesStatus != "GENERATING"
+	// 入力検証プログラムの言語は提出の判定に使わないので、問題の検証のときに確かめる
+	setting.validatorLang = responseData.Problem.ValidatorLang
+	setting.judgePolicy, err = validateJudgePolicy(responseData.Problem.JudgePolicy, setting.subtasks)
 	if err != nil {
-		return nil, err
+		return setting, err
 	}
-	switch responseData.Problem.JudgeType {
+	return setting, nil
+}
+
+func getJudgeType(judgeType string, judgeLang string, spjudgelangs map[string]SpecialJudgeLang, definitions map[string]LanguageDefinition) (JudgeType, error) {
+	switch judgeType {
 	case "NORMAL":
 		return NormalJudge{}, nil
 	case "SPECIAL":
-		lang, exist := spjudgelangs[responseData.Problem.JudgeLang]
-		if !exist {
-			return nil, fmt.Errorf("special judge lang not found: %s", responseData.Problem.JudgeLang)
+		definition, err := getJudgeLang(judgeLang, spjudgelangs, definitions)
+		if err != nil {
+			return nil, err
 		}
-		definition, exist := definitions[lang.Id]
-		if !exist {
-			return nil, fmt.Errorf("special judge language not found: %s", lang.Id)
+		return SpecialJudge{definition, spjudgelangs[judgeLang].wrongAnswerExitCodes()}, nil
+	case "INTERACTIVE":
+		definition, err := getJudgeLang(judgeLang, spjudgelangs, definitions)
+		if err != nil {
+			return nil, err
 		}
-		return SpecialJudge{definition}, nil
+		return InteractiveJudge{definition, spjudgelangs[judgeLang].wrongAnswerExitCodes()}, nil
 	default:
-		return nil, fmt.Errorf("unknown judgeType '%s'", responseData.Problem.JudgeType)
+		return nil, fmt.Errorf("unknown judgeType '%s'", judgeType)
+	}
+}
+
+func getJudgeLang(judgeLang string, spjudgelangs map[string]SpecialJudgeLang, definitions map[string]LanguageDefinition) (LanguageDefinition, error) {
+	lang, exist := spjudgelangs[judgeLang]
+	if !exist {
+		return LanguageDefinition{}, fmt.Errorf("special judge lang not found: %s", judgeLang)
+	}
+	definition, exist := definitions[lang.Id]
+	if !exist {
+		return LanguageDefinition{}, fmt.Errorf("special judge language not found: %s", lang.Id)
+	}
+	return definition, nil
+}
+
+// judgeProgramLang は問題側が用意するプログラム (特殊ジャッジ・インタラクタ) の言語を返す
+func judgeProgramLang(jType JudgeType) (LanguageDefinition, bool) {
+	switch jt := jType.(type) {
+	case SpecialJudge:
+		return jt.lang, true
+	case InteractiveJudge:
+		return jt.lang, true
+	}
+	return LanguageDefinition{}, false
+}
+
+func updateSubmission(ctx context.Context, id string, userID string, status string, stderr *string, testcases *[]TestcaseResultInput, judgeLog *string) error {
+	return sendSubmissionUpdate(ctx, UpdateSubmissionStatusInput{ID: id, UserID: userID, Status: status, Stderr: stderr, Testcases: testcases, JudgeLog: judgeLog})
+}
+
+// updateSubmissionCompileResult はコンパイラの出力と、そこから取り出した診断を提出に添付する
+func updateSubmissionCompileResult(ctx context.Context, id string, userID string, status string, result CompileResult) error {
+	diagnostics := result.diagnostics
+	if diagnostics == nil {
+		diagnostics = []CompileDiagnostic{}
 	}
+	return sendSubmissionUpdate(ctx, UpdateSubmissionStatusInput{ID: id, UserID: userID, Status: status, Stderr: &result.output, Diagnostics: &diagnostics})
 }
 
-func updateSubmission(id string, userID string, status string, stderr *string, testcases *[]TestcaseResultInput) error {
+func sendSubmissionUpdate(ctx context.Context, input UpdateSubmissionStatusInput) error {
 	variables := make(map[string]interface{})
 	query := `
 		mutation UpdateSubmission($input: UpdateSubmissionInput!) {
@@ -95,8 +209,8 @@ func updateSubmission(id string, userID string, status string, stderr *string, t
 			}
 		}
 	`
-	variables["input"] = UpdateSubmissionStatusInput{id, userID, status, stderr, testcases}
-	err := requestGraphql(query, variables, nil)
+	variables["input"] = input
+	err := requestGraphql(ctx, query, variables, nil)
 	return err
 }
 
@@ -141,27 +255,51 @@ func disallowAccessTestcases(testcasesPath string) error {
 	return nil
 }
 
-func judge(definition LanguageDefinition, data JudgeQueueData, jType JudgeType) error {
-	const errorMessage = "failed to judge a submission: %v"
-	var err error
+// downloadTestcases は問題のテストケースを展開し、入力ファイル名の一覧を返す
+// readTestcasesKey は判定に使うテストケースの zip のキーを読む。生成したものでなければ problemID.zip に置かれている
+func readTestcasesKey(ctx context.Context, problemID string) (string, error) {
+	res, err := database.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(PROBLEM_TABLE_NAME),
+		Key:                  map[string]*dynamodb.AttributeValue{"id": {S: aws.String(problemID)}},
+		ProjectionExpression: aws.String("testcasesKey"),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if key := res.Item["testcasesKey"]; key != nil {
+		return aws.StringValue(key.S), nil
+	}
+	return problemID + ".zip", nil
+}
+
+func downloadTestcases(ctx context.Context, problemID string) (string, []string, error) {
+	key, err := readTestcasesKey(ctx, problemID)
+	if err != nil {
+		return "", nil, err
+	}
+	return downloadTestcasesFrom(ctx, TESTCASES_BUCKET_NAME, key)
+}
+
+func downloadTestcasesFrom(ctx context.Context, bucket, key string) (string, []string, error) {
 	testcasesPath := filepath.Join(TEMP_DIR, "testcases")
 	testcasesZipPath := testcasesPath + ".zip"
-	if err = os.RemoveAll(testcasesPath); err != nil {
-		return fmt.Errorf(errorMessage, err)
+	if err := os.RemoveAll(testcasesPath); err != nil {
+		return testcasesPath, nil, err
 	}
-	if err = os.Remove(testcasesZipPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf(errorMessage, err)
+	if err := os.Remove(testcasesZipPath); err != nil && !os.IsNotExist(err) {
+		return testcasesPath, nil, err
 	}
-	err = downloadFromStorage(testcasesZipPath, TESTCASES_BUCKET_NAME, data.ProblemID+".zip")
+	err := downloadFromStorage(ctx, testcasesZipPath, bucket, key)
 	if err != nil {
-		return fmt.Errorf(errorMessage, err)
+		return testcasesPath, nil, err
 	}
 	if err = os.Chmod(testcasesZipPath, 0600); err != nil {
-		return fmt.Errorf(errorMessage, err)
+		return testcasesPath, nil, err
 	}
 	err = unzip(testcasesZipPath, testcasesPath)
 	if err != nil {
-		return fmt.Errorf(errorMessage, err)
+		return testcasesPath, nil, err
 	}
 	inPath := filepath.Join(testcasesPath, "in")
 	outPath := filepath.Join(testcasesPath, "out")
@@ -170,124 +308,199 @@ func judge(definition LanguageDefinition, data JudgeQueueData, jType JudgeType)
 	os.Chmod(outPath, 0775)
 	inTestcases, err := os.ReadDir(inPath)
 	if err != nil {
-		return fmt.Errorf(errorMessage, err)
+		return testcasesPath, nil, err
 	}
-	testcases := []TestcaseResultInput{}
+	names := []string{}
 	for _, inTestcase := range inTestcases {
 		if inTestcase.IsDir() {
 			continue
 		}
-		testcases = append(testcases, TestcaseResultInput{inTestcase.Name(), "WJ", -1, -1})
+		names = append(names, inTestcase.Name())
 	}
-
 	setTestcasePermisson(testcasesPath)
-testCasesLoop:
-	for i := range testcases {
-		log.Printf("Judging %s...", testcases[i].Name)
-		inTestcaseFilePath := filepath.Join(inPath, testcases[i].Name)
-		inTestcaseFile, err := os.Open(inTestcaseFilePath)
+	return testcasesPath, names, nil
+}
+
+const JUDGE_TIME_LIMIT = 2             // 秒
+const JUDGE_MEMORY_LIMIT = 1024 * 1024 // KB
+
+type TestcaseJudgement struct {
+	testcase TestcaseResultInput
+	result   RunResult // 提出されたプログラムの実行結果
+	stop     bool      // true なら以降のテストケースは判定しない
+}
+
+// judgeTestcase は 1 つのテストケースを実行して判定する
+func judgeTestcase(ctx context.Context, definition LanguageDefinition, problem ProblemSetting, testcasesPath string, name string, stdout *strings.Builder, stderr io.Writer, judgeLog io.Writer) (TestcaseJudgement, error) {
+	judgement := TestcaseJudgement{testcase: TestcaseResultInput{Name: name, Status: "WJ", Time: -1, Memory: -1}}
+	testcase := &judgement.testcase
+	log.Printf("Judging %s...", name)
+	inTestcaseFilePath := filepath.Join(testcasesPath, "in", name)
+	outTestcaseFilePath := filepath.Join(testcasesPath, "out", name)
+	inTestcaseFile, err := os.Open(inTestcaseFilePath)
+	if err != nil {
+		return judgement, err
+	}
+	defer inTestcaseFile.Close()
+	disallowAccessTestcases(testcasesPath)
+	config := RunConfig{
+		stdin:          inTestcaseFile,
+		stdout:         stdout,
+		stderr:         stderr,
+		timeLimit:      JUDGE_TIME_LIMIT,
+		memoryLimit:    JUDGE_MEMORY_LIMIT,
+		dir:            TEMP_DIR,
+		runCommandArgs: []string{},
+	}
+	jType := problem.judgeType
+	if jt, ok := jType.(InteractiveJudge); ok {
+		outTestcaseFile, err := os.Open(outTestcaseFilePath)
 		if err != nil {
-			return fmt.Errorf(errorMessage, err)
+			return judgement, err
+		}
+		defer outTestcaseFile.Close()
+		interactorStderr := newLimitedWriter(SPECIAL_JUDGE_LOG_LIMIT)
+		result, interactorResult, err := jt.runInteractiveJudge(ctx, definition, config, interactorStderr, inTestcaseFile, outTestcaseFile)
+		if err != nil {
+			return judgement, err
+		}
+		testcase.Time = result.time
+		testcase.Memory = result.memory
+		testcase.Status = interactiveJudgeStatus(result, interactorResult, jt.wrongAnswerExitCodes)
+		if testcase.Status == "RE" {
+			detail := result.detail()
+			testcase.Detail = &detail
 		}
-		disallowAccessTestcases(testcasesPath)
-		outTestcaseFilePath := filepath.Join(outPath, testcases[i].Name)
-		stdoutWriter := strings.Builder{}
-		config := RunConfig{
-			stdin:          inTestcaseFile,
-			stdout:         &stdoutWriter,
-			stderr:         nil,
-			timeLimit:      2,
-			memoryLimit:    1024 * 1024,
-			dir:            TEMP_DIR,
-			runCommandArgs: []string{},
+		if specialJudgeStatus(interactorResult, jt.wrongAnswerExitCodes) == "JRE" || interactorStderr.String() != "" {
+			fmt.Fprintf(judgeLog, "[%s] %s (exit code %d)\n%s\n", name, testcase.Status, interactorResult.exitCode, interactorStderr.String())
 		}
-		result, err := run(definition, config)
-		inTestcaseFile.Close()
+		judgement.result = result
+		judgement.stop = result.status == RunResultStatusTimeLimitExceeded || interactorResult.status == RunResultStatusTimeLimitExceeded
+		return judgement, nil
+	}
+	var result RunResult
+	if problem.problemType == PROBLEM_TYPE_OUTPUT_ONLY {
+		err = readOutputOnlyAnswer(name, stdout)
+	} else {
+		result, err = run(ctx, definition, config)
+	}
+	if err != nil {
+		return judgement, err
+	}
+	judgement.result = result
+	testcase.Time = result.time
+	testcase.Memory = result.memory
+	if result.status != RunResultStatusSuccess {
+		switch result.status {
+		case RunResultStatusTimeLimitExceeded:
+			testcase.Status = "TLE"
+		case RunResultStatusMemoryLimitExceeded:
+			testcase.Status = "MLE"
+		case RunResultStatusRunTimeError:
+			testcase.Status = "RE"
+			detail := result.detail()
+			testcase.Detail = &detail
+		}
+		judgement.stop = result.status == RunResultStatusTimeLimitExceeded
+		return judgement, nil
+	}
+	stdoutReader := strings.NewReader(stdout.String())
+
+	switch jt := jType.(type) {
+	case SpecialJudge:
+		log.Printf("run special judge for testcase: %s", name)
+		allowAccessTestcases(testcasesPath)
+		judgeStderr := newLimitedWriter(SPECIAL_JUDGE_LOG_LIMIT)
+		result, err := jt.runSpecialJudge(ctx, jt.lang, stdoutReader, judgeStderr, inTestcaseFilePath, outTestcaseFilePath)
 		if err != nil {
+			return judgement, err
+		}
+		testcase.Status = specialJudgeStatus(result, jt.wrongAnswerExitCodes)
+		if testcase.Status == "JRE" || judgeStderr.String() != "" {
+			fmt.Fprintf(judgeLog, "[%s] %s (exit code %d)\n%s\n", name, testcase.Status, result.exitCode, judgeStderr.String())
+		}
+		judgement.stop = result.status == RunResultStatusTimeLimitExceeded
+		return judgement, nil
+	case NormalJudge:
+		log.Println("run normal judge")
+		outTestcaseFile, err := os.Open(outTestcaseFilePath)
+		if err != nil {
+			return judgement, err
+		}
+		defer outTestcaseFile.Close()
+		accuracy, _, _ := big.ParseFloat("0.000001", 10, PRECISION, big.ToNearestEven)
+		checkResult, err := jt.check(stdoutReader, outTestcaseFile, accuracy, PRECISION)
+		if err != nil {
+			return judgement, err
+		}
+		if checkResult {
+			testcase.Status = "AC"
+		} else {
+			testcase.Status = "WA"
+		}
+		return judgement, nil
+	}
+	return judgement, fmt.Errorf("unknown judgeType")
+}
+
+// judge は ctx が取り消されると残りのテストケースを判定せずに ctx のエラーを返す
+func judge(ctx context.Context, definition LanguageDefinition, data JudgeQueueData, problem ProblemSetting) error {
+	const errorMessage = "failed to judge a submission: %v"
+	testcasesPath, names, err := downloadTestcases(ctx, data.ProblemID)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	previous, err := previousTestcaseResults(ctx, data)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	testcases := []TestcaseResultInput{}
+	for _, name := range names {
+		testcases = append(testcases, TestcaseResultInput{Name: name, Status: "WJ", Time: -1, Memory: -1})
+	}
+
+	judgeLog := newLimitedWriter(SPECIAL_JUDGE_LOG_LIMIT)
+	skipper := newTestcaseSkipper(problem)
+	progress := newProgressReporter(func() error {
+		return updateSubmission(ctx, data.SubmissionID, data.UserID, "WJ", nil, &testcases, nil)
+	})
+	for i := range testcases {
+		if err := ctx.Err(); err != nil {
 			return fmt.Errorf(errorMessage, err)
 		}
-		testcases[i].Time = result.time
-		testcases[i].Memory = result.memory
-		if result.status != RunResultStatusSuccess {
-			switch result.status {
-			case RunResultStatusTimeLimitExceeded:
-				testcases[i].Status = "TLE"
-			case RunResultStatusMemoryLimitExceeded:
-				testcases[i].Status = "MLE"
-			case RunResultStatusRunTimeError:
-				testcases[i].Status = "RE"
-			}
-			log.Println(testcases[i].Status)
-			err = updateSubmission(data.SubmissionID, data.UserID, "WJ", nil, &testcases)
-			if err != nil {
-				return fmt.Errorf(errorMessage, err)
-			}
-			if result.status == RunResultStatusTimeLimitExceeded {
-				break
-			}
+		if result, ok := previous[testcases[i].Name]; ok {
+			testcases[i] = result
+			skipper.record(reusedJudgement(result))
 			continue
 		}
-		stdoutReader := strings.NewReader(stdoutWriter.String())
-
-		switch jt := jType.(type) {
-		case SpecialJudge:
-			log.Printf("run special judge for testcase: %s", testcases[i].Name)
-			allowAccessTestcases(testcasesPath)
-			result, err := jt.runSpecialJudge(jt.lang, stdoutReader, inTestcaseFilePath, outTestcaseFilePath)
-			if err != nil {
-				return fmt.Errorf(errorMessage, err)
-			}
-			switch result.status {
-			case RunResultStatusSuccess:
-				testcases[i].Status = "AC"
-			case RunResultStatusTimeLimitExceeded:
-				testcases[i].Status = "JTLE"
-			case RunResultStatusMemoryLimitExceeded:
-				testcases[i].Status = "JMLE"
-			case RunResultStatusRunTimeError:
-				testcases[i].Status = "WA" // ジャッジプログラムの実行エラーはWAとする
-			}
-
-			log.Println(testcases[i].Status)
-			err = updateSubmission(data.SubmissionID, data.UserID, "WJ", nil, &testcases)
-			if err != nil {
-				return fmt.Errorf(errorMessage, err)
-			}
-			if result.status == RunResultStatusTimeLimitExceeded {
-				break testCasesLoop
-			}
-		case NormalJudge:
-			log.Println("run normal judge")
-			outTestcaseFile, err := os.Open(outTestcaseFilePath)
-			if err != nil {
-				return fmt.Errorf(errorMessage, err)
-			}
-			accuracy, _, _ := big.ParseFloat("0.000001", 10, PRECISION, big.ToNearestEven)
-			checkResult, err := jt.check(stdoutReader, outTestcaseFile, accuracy, PRECISION)
-			if err != nil {
-				return fmt.Errorf(errorMessage, err)
-			}
-			if checkResult {
-				log.Println("AC")
-				testcases[i].Status = "AC"
-				err = updateSubmission(data.SubmissionID, data.UserID, "WJ", nil, &testcases)
-				if err != nil {
-					return fmt.Errorf(errorMessage, err)
-				}
-			} else {
-				log.Println("WA")
-				testcases[i].Status = "WA"
-				err = updateSubmission(data.SubmissionID, data.UserID, "WJ", nil, &testcases)
-				if err != nil {
-					return fmt.Errorf(errorMessage, err)
-				}
-			}
-		default:
-			return fmt.Errorf("unknown judgeType")
+		if skipper.skip(testcases[i].Name) {
+			testcases[i].Status = "SKIPPED"
+			continue
 		}
-
+		var stdout strings.Builder
+		judgement, err := judgeTestcase(ctx, definition, problem, testcasesPath, testcases[i].Name, &stdout, nil, judgeLog)
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+		testcases[i] = judgement.testcase
+		log.Println(judgement.testcase.Status)
+		err = progress.update()
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+		skipper.record(judgement)
+	}
+	var judgeLogString *string
+	if judgeLogText := judgeLog.String(); judgeLogText != "" {
+		judgeLogString = &judgeLogText
+	}
+	summary, err := summarizeTestcases(testcases)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
 	}
-	err = updateSubmission(data.SubmissionID, data.UserID, "JUDGED", nil, nil)
+	log.Printf("Verdict: %s (%d/%d)", summary.Verdict, summary.PassedCount, len(testcases))
+	// 途中経過は間引いているので、最終状態のテストケースも一緒に送る
+	err = sendSubmissionUpdate(ctx, UpdateSubmissionStatusInput{ID: data.SubmissionID, UserID: data.UserID, Status: "JUDGED", Testcases: &testcases, JudgeLog: judgeLogString, SubmissionSummary: &summary})
 	if err != nil {
 		return fmt.Errorf(errorMessage, err)
 	}
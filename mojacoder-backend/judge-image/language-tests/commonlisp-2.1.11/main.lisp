@@ -0,0 +1 @@
+(format t "Hello, World!~%")
@@ -3,13 +3,88 @@ package main
 import (
 	"encoding/json"
 	"io/ioutil"
+	"math"
 	"os"
+	"sort"
 )
 
+const DEFAULT_COMPILE_TIME_LIMIT = 30            // 秒
+const DEFAULT_COMPILE_MEMORY_LIMIT = 1536 * 1024 // KB
+
+// COMPILE_ADDRESS_SPACE_MARGIN は共有ライブラリやスレッドのスタックなど、実際には使われない仮想メモリの分
+const COMPILE_ADDRESS_SPACE_MARGIN = 512 * 1024 // KB
+
+// LanguageDefinition は language-definition.json の各言語の定義。
+// 制限の項目は 0 のとき既定値を使い、versionCommand は出力に version が含まれるかを validate-languages で確かめる。
 type LanguageDefinition struct {
-	Filename       string `json:"filename"`
-	CompileCommand string `json:"compileCommand"`
-	RunCommand     string `json:"runCommand"`
+	Name                         string            `json:"name"`
+	Version                      string            `json:"version"`
+	VersionCommand               string            `json:"versionCommand"`
+	Filename                     string            `json:"filename"`
+	CompileCommand               string            `json:"compileCommand"`
+	RunCommand                   string            `json:"runCommand"`
+	GraderFilename               string            `json:"graderFilename"`
+	GraderCompileCommand         string            `json:"graderCompileCommand"`
+	GraderRunCommand             string            `json:"graderRunCommand"`
+	CompileTimeLimit             int               `json:"compileTimeLimit"`             // 秒
+	CompileMemoryLimit           int               `json:"compileMemoryLimit"`           // KB
+	CompileAddressSpaceAllowance int               `json:"compileAddressSpaceAllowance"` // KB, JVM や Go など起動時に大きな仮想メモリを予約する処理系向け
+	TimeLimitMultiplier          float64           `json:"timeLimitMultiplier"`
+	AdditionalMemory             int               `json:"additionalMemory"` // KB, JVM や Mono など起動だけでメモリを使う処理系向け
+	Environment                  map[string]string `json:"environment"`
+	ShowCompileWarnings          bool              `json:"showCompileWarnings"`
+}
+
+func (d LanguageDefinition) compileTimeLimit() int {
+	if d.CompileTimeLimit > 0 {
+		return d.CompileTimeLimit
+	}
+	return DEFAULT_COMPILE_TIME_LIMIT
+}
+
+func (d LanguageDefinition) compileMemoryLimit() int {
+	if d.CompileMemoryLimit > 0 {
+		return d.CompileMemoryLimit
+	}
+	return DEFAULT_COMPILE_MEMORY_LIMIT
+}
+
+// compileAddressSpaceLimit はコンパイラの仮想メモリの上限を返す。
+// ulimit -m (RSS) は今の Linux では効かないので、終了を待たずにメモリを使い切らせないためにはこちらで抑える
+func (d LanguageDefinition) compileAddressSpaceLimit() int {
+	return d.compileMemoryLimit() + COMPILE_ADDRESS_SPACE_MARGIN + d.CompileAddressSpaceAllowance
+}
+
+func (d LanguageDefinition) timeLimitMultiplier() float64 {
+	if d.TimeLimitMultiplier <= 0 {
+		return 1
+	}
+	return d.TimeLimitMultiplier
+}
+
+// timeLimitMillis は秒単位の実行時間制限に倍率を掛けてミリ秒で返す
+func (d LanguageDefinition) timeLimitMillis(timeLimit int) int {
+	return int(math.Round(float64(timeLimit*1000) * d.timeLimitMultiplier()))
+}
+
+func (d LanguageDefinition) environment() []string {
+	environment := make([]string, 0, len(d.Environment))
+	for name, value := range d.Environment {
+		environment = append(environment, name+"="+value)
+	}
+	sort.Strings(environment)
+	return environment
+}
+
+// GRADER_SOURCES_PLACEHOLDER は graderCompileCommand 中でソースファイルの一覧に置き換えられる
+const GRADER_SOURCES_PLACEHOLDER = "{sources}"
+
+// withGrader は採点用プログラムと一緒に実行するときの言語定義を返す
+func (d LanguageDefinition) withGrader() LanguageDefinition {
+	if d.GraderRunCommand != "" {
+		d.RunCommand = d.GraderRunCommand
+	}
+	return d
 }
 
 func loadLanguageDefinition(file string) (map[string]LanguageDefinition, error) {
@@ -28,6 +103,13 @@ func loadLanguageDefinition(file string) (map[string]LanguageDefinition, error)
 
 type SpecialJudgeLang struct {
 	Id string `json:"id"`
+	// WrongAnswerExitCodes は特殊ジャッジが不正解を返すときの終了コード。
+	// 省略した場合は 0 以外のすべての終了コードを不正解とする
+	WrongAnswerExitCodes []int `json:"wrongAnswerExitCodes,omitempty"`
+}
+
+func (l SpecialJudgeLang) wrongAnswerExitCodes() []int {
+	return l.WrongAnswerExitCodes
 }
 
 func loadSpecialJudgeLangs(file string) (map[string]SpecialJudgeLang, error) {
@@ -1,6 +1,11 @@
 package main
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestLoadSpecialJudgeLangs(t *testing.T) {
 	var err error
@@ -28,9 +33,15 @@ func TestLoadLanguageDefinition(t *testing.T) {
 	}
 
 	for _, v := range definitions {
+		if v.Name == "" {
+			t.Error("name is empty")
+		}
 		if v.Filename == "" {
 			t.Error("filename is empty")
 		}
+		if v.TimeLimitMultiplier < 0 || v.CompileTimeLimit < 0 || v.CompileMemoryLimit < 0 || v.AdditionalMemory < 0 {
+			t.Error(v.Name + ": limits must not be negative")
+		}
 		if v.RunCommand == "" {
 			t.Error("runCommand is empty")
 		}
@@ -60,3 +71,79 @@ func TestSpecialJudgeLangsId(t *testing.T) {
 		}
 	}
 }
+
+func TestGraderDefinitions(t *testing.T) {
+	definitions, err := loadLanguageDefinition("./language-definition.json")
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	for id, v := range definitions {
+		if v.GraderFilename == "" {
+			if v.GraderCompileCommand != "" || v.GraderRunCommand != "" {
+				t.Error(id + ": graderFilename is empty")
+			}
+			continue
+		}
+		if v.GraderCompileCommand != "" && !strings.Contains(v.GraderCompileCommand, GRADER_SOURCES_PLACEHOLDER) {
+			t.Error(id + ": graderCompileCommand doesn't contain " + GRADER_SOURCES_PLACEHOLDER)
+		}
+	}
+}
+
+func TestLanguageDefinitionLimits(t *testing.T) {
+	var definition LanguageDefinition
+	if definition.timeLimitMillis(2) != 2000 {
+		t.Errorf("default timeLimitMillis(2) = %d; expected 2000", definition.timeLimitMillis(2))
+	}
+	if definition.compileTimeLimit() != DEFAULT_COMPILE_TIME_LIMIT || definition.compileMemoryLimit() != DEFAULT_COMPILE_MEMORY_LIMIT {
+		t.Error("default compile limits are not applied")
+	}
+	if definition.compileAddressSpaceLimit() != DEFAULT_COMPILE_MEMORY_LIMIT+COMPILE_ADDRESS_SPACE_MARGIN {
+		t.Errorf("compileAddressSpaceLimit() = %d", definition.compileAddressSpaceLimit())
+	}
+	definition.TimeLimitMultiplier = 1.5
+	definition.Environment = map[string]string{"B": "2", "A": "1"}
+	if definition.timeLimitMillis(2) != 3000 {
+		t.Errorf("timeLimitMillis(2) = %d; expected 3000", definition.timeLimitMillis(2))
+	}
+	if env := strings.Join(definition.environment(), ","); env != "A=1,B=2" {
+		t.Errorf("environment() = %s; expected A=1,B=2", env)
+	}
+}
+
+func TestInterpretedLanguageTimeLimitMultiplier(t *testing.T) {
+	definitions, err := loadLanguageDefinition("./language-definition.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"python3.11", "pypy3-7.3.13", "ruby-3.2.2"} {
+		if definitions[id].timeLimitMultiplier() <= 1 {
+			t.Errorf("%s must have a time limit multiplier", id)
+		}
+	}
+	if definitions["g++-12.3"].timeLimitMultiplier() != 1 {
+		t.Error("compiled languages must keep the original time limit")
+	}
+}
+
+func TestLanguageTestsExist(t *testing.T) {
+	definitions, err := loadLanguageDefinition("./language-definition.json")
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	for id, v := range definitions {
+		if _, err := os.Stat(filepath.Join(LANGUAGE_TESTS_DIR, id, v.Filename)); err != nil {
+			t.Error(id + ": " + err.Error())
+		}
+		if v.VersionCommand != "" && v.Version == "" {
+			t.Error(id + ": version is empty")
+		}
+		if v.Version != "" && !strings.HasSuffix(id, v.Version) {
+			t.Error(id + ": id doesn't match version " + v.Version)
+		}
+	}
+}
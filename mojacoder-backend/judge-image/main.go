@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -36,91 +41,161 @@ func initDirectory() error {
 	if err := os.RemoveAll(SPECIAL_JUDGE_DIR); err != nil {
 		return err
 	}
+	if err := os.RemoveAll(VALIDATOR_DIR); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(GENERATOR_DIR); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(GENERATOR_SOLUTION_DIR); err != nil {
+		return err
+	}
 	return nil
 }
 
-func processCode(definitions map[string]LanguageDefinition, data JudgeQueueData, spjudgelangs map[string]SpecialJudgeLang) error {
+func processCode(ctx context.Context, definitions map[string]LanguageDefinition, data JudgeQueueData, spjudgelangs map[string]SpecialJudgeLang) error {
 	const errorMessage = "failed to process a code: %v"
 	var err error
 	err = initDirectory()
+	if data.Type == "VALIDATE_PROBLEM" {
+		return validateProblem(ctx, definitions, spjudgelangs, data)
+	}
+	if data.Type == "GENERATE_TESTCASES" {
+		return generateProblemTestcases(ctx, definitions, spjudgelangs, data)
+	}
+	if data.Type == "REJUDGE" {
+		if data.SubmissionID == "" {
+			return rejudgeProblem(ctx, data)
+		}
+		data, err = prepareRejudge(ctx, data)
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+	}
+	var problem ProblemSetting
+	if data.Type == "SUBMISSION" || data.Type == "SAMPLE_TEST" {
+		log.Printf("Getting problem setting of problem ID '%s'...", data.ProblemID)
+		problem, err = getProblemSetting(ctx, data.ProblemID, spjudgelangs, definitions)
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+		// 提出の受付時にも確かめているが、生成中に届いたものは WJ のまま待たせ、SQS の再配信で生成後に判定する
+		if !problem.testcasesReady {
+			if data.Type == "SAMPLE_TEST" {
+				err = responsePlaygroundError(ctx, data, "IE", "Testcases of this problem are being generated.")
+				if err != nil {
+					return fmt.Errorf(errorMessage, err)
+				}
+				return nil
+			}
+			return fmt.Errorf("%w: problem %s", errTestcasesNotReady, data.ProblemID)
+		}
+		if problem.problemType == PROBLEM_TYPE_OUTPUT_ONLY && data.Type == "SAMPLE_TEST" {
+			err = responsePlaygroundError(ctx, data, "CE", "Output only problems do not support sample tests.")
+			if err != nil {
+				return fmt.Errorf(errorMessage, err)
+			}
+			return nil
+		}
+		if problem.problemType == PROBLEM_TYPE_OUTPUT_ONLY {
+			err = judgeOutputOnly(ctx, data, problem)
+			if err != nil {
+				return fmt.Errorf(errorMessage, err)
+			}
+			return nil
+		}
+	}
 	definition, exist := definitions[data.Lang]
 	if !exist {
 		return fmt.Errorf("language not found: %s", data.Lang)
 	}
 
+	var sources []string
+	if problem.problemType == PROBLEM_TYPE_GRADER {
+		log.Printf("Downloading grader for problem: %s", data.ProblemID)
+		prepared, err := prepareGrader(ctx, data.ProblemID, data.Lang, definition)
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+		if !prepared {
+			message := fmt.Sprintf("This problem does not support %s.", data.Lang)
+			err = reportCompileResult(ctx, data, CompileResult{status: CompileResultStatusCompileError, output: message})
+			if err != nil {
+				return fmt.Errorf(errorMessage, err)
+			}
+			return nil
+		}
+		definition = definition.withGrader()
+		sources = []string{definition.GraderFilename, definition.Filename}
+	}
+
 	switch data.Type {
-	case "PLAYGROUND":
+	case "PLAYGROUND", "SAMPLE_TEST":
 		log.Printf("Downloading code for playground: %s", data.SessionID)
-		err = downloadFromStorage(filepath.Join(TEMP_DIR, definition.Filename), PLAYGROUND_CODE_BUCKET_NAME, data.SessionID)
+		err = downloadFromStorage(ctx, filepath.Join(TEMP_DIR, definition.Filename), PLAYGROUND_CODE_BUCKET_NAME, data.SessionID)
 	case "SUBMISSION":
 		log.Printf("Downloading code for submission: %s", data.SubmissionID)
-		err = downloadFromStorage(filepath.Join(TEMP_DIR, definition.Filename), SUBMITTED_CODE_BUCKET_NAME, data.SubmissionID)
+		err = downloadFromStorage(ctx, filepath.Join(TEMP_DIR, definition.Filename), SUBMITTED_CODE_BUCKET_NAME, data.SubmissionID)
 	}
 	if err != nil {
 		return fmt.Errorf(errorMessage, err)
 	}
 
-	var compiled bool
-	var stderr string
-	compiled, stderr, err = compile(definition, TEMP_DIR)
+	var compileResult CompileResult
+	extracted, message, err := extractSubmittedArchive(TEMP_DIR, definition.Filename)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	if extracted {
+		compileResult, err = compile(ctx, definition, TEMP_DIR, sources...)
+	} else {
+		compileResult = CompileResult{status: CompileResultStatusCompileError, output: message}
+	}
 
 	if err != nil {
 		return fmt.Errorf(errorMessage, err)
 	}
-	if !compiled {
-		log.Printf("Compile Error: %s", stderr)
-		switch data.Type {
-		case "PLAYGROUND":
-			err = responsePlayground(data.SessionID, data.UserID, -1, -1, -1, "", stderr)
-		case "SUBMISSION":
-			err = updateSubmission(data.SubmissionID, data.UserID, "CE", &stderr, nil)
-		}
+	stderr := compileResult.output
+	if !compileResult.compiled() {
+		log.Printf("Compile Error (%s): %s", compileResult.verdict(), stderr)
+		err = reportCompileResult(ctx, data, compileResult)
 		if err != nil {
 			return fmt.Errorf(errorMessage, err)
 		}
 		return nil
 	}
 	if data.Type == "PLAYGROUND" {
-		err = testCode(definition, data)
+		err = testCode(ctx, definition, data, compileResult.output)
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+	} else if data.Type == "SAMPLE_TEST" {
+		prepared, _, err := prepareJudgeProgram(ctx, data, problem.judgeType)
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+		if !prepared {
+			// ジャッジプログラムのコンパイルエラーは問題の作者向けなので詳細は返さない
+			return responsePlaygroundError(ctx, data, "JCE", "")
+		}
+		err = sampleTest(ctx, definition, data, problem, compileResult.output)
 		if err != nil {
 			return fmt.Errorf(errorMessage, err)
 		}
 	} else if data.Type == "SUBMISSION" {
-		log.Printf("Getting judge type problem ID '%s'...", data.ProblemID)
-		judgeType, err := getJudgeType(data.ProblemID, spjudgelangs, definitions)
+		prepared, judgeCompileOutput, err := prepareJudgeProgram(ctx, data, problem.judgeType)
 		if err != nil {
 			return fmt.Errorf(errorMessage, err)
 		}
-		if jt, ok := judgeType.(SpecialJudge); ok {
-			log.Printf("Downloading special judge code for submission: %s\n", data.SubmissionID)
-			lang := jt.lang
-			err = resetSandboxDirectory(SPECIAL_JUDGE_DIR)
-			if err != nil {
-				return fmt.Errorf(errorMessage, err)
-			}
-			err = downloadFromStorage(filepath.Join(SPECIAL_JUDGE_DIR, lang.Filename), JUDGECODES_BUCKET_NAME, data.ProblemID)
-			if err != nil {
-				return fmt.Errorf(errorMessage, err)
-			}
-			compiled, stderr, err = compile(lang, SPECIAL_JUDGE_DIR)
-			if err != nil {
-				return fmt.Errorf(errorMessage, err)
-			}
-			if !compiled {
-				err = updateSubmission(data.SubmissionID, data.UserID, "JCE", nil, nil)
-				log.Println("Special Judge Compile Error: " + stderr)
-				if err != nil {
-					return fmt.Errorf(errorMessage, err)
-				}
-				return nil
-			}
+		if !prepared {
+			return updateSubmission(ctx, data.SubmissionID, data.UserID, "JCE", nil, nil, &judgeCompileOutput)
 		}
 
-		err = updateSubmission(data.SubmissionID, data.UserID, "WJ", &stderr, nil)
+		err = updateSubmissionCompileResult(ctx, data.SubmissionID, data.UserID, "WJ", compileResult)
 		if err != nil {
 			return fmt.Errorf(errorMessage, err)
 		}
-		err = judge(definition, data, judgeType)
+		err = judge(ctx, definition, data, problem)
 		if err != nil {
 			return fmt.Errorf(errorMessage, err)
 		}
@@ -128,29 +203,128 @@ func processCode(definitions map[string]LanguageDefinition, data JudgeQueueData,
 	return nil
 }
 
+// reportCompileResult はコンパイルできなかったことをジョブの種類に応じた送り先に返す
+func reportCompileResult(ctx context.Context, data JudgeQueueData, compileResult CompileResult) error {
+	switch data.Type {
+	case "PLAYGROUND", "SAMPLE_TEST":
+		return responsePlaygroundError(ctx, data, compileResult.verdict(), compileResult.output)
+	case "SUBMISSION":
+		return updateSubmissionCompileResult(ctx, data.SubmissionID, data.UserID, compileResult.verdict(), compileResult)
+	}
+	return nil
+}
+
+// reportInternalError はジョブを処理できなかったことを、結果を待っている提出や問題に記録する
+func reportInternalError(ctx context.Context, data JudgeQueueData) error {
+	switch data.Type {
+	case "SUBMISSION":
+		return updateSubmission(ctx, data.SubmissionID, data.UserID, "IE", nil, nil, nil)
+	case "REJUDGE":
+		// 再ジャッジのメッセージには利用者が含まれないので、提出から読む
+		if data.SubmissionID != "" {
+			submission, err := getSubmissionRecord(ctx, data.SubmissionID)
+			if err != nil {
+				return err
+			}
+			return updateSubmission(ctx, data.SubmissionID, submission.UserID, "IE", nil, nil, nil)
+		}
+	case "PLAYGROUND", "SAMPLE_TEST":
+		return responsePlaygroundError(ctx, data, "IE", "")
+	case "VALIDATE_PROBLEM", "GENERATE_TESTCASES":
+		return updateProblemValidation(ctx, data.ProblemID, newProblemValidation("FAILED", "Internal error.", nil, nil))
+	}
+	return nil
+}
+
+// prepareJudgeProgram は特殊ジャッジやインタラクタなど問題側のプログラムを用意する。
+// コンパイルに失敗した場合は false とコンパイラの出力を返す。
+func prepareJudgeProgram(ctx context.Context, data JudgeQueueData, jType JudgeType) (bool, string, error) {
+	lang, ok := judgeProgramLang(jType)
+	if !ok {
+		return true, "", nil
+	}
+	log.Printf("Downloading judge code for problem: %s\n", data.ProblemID)
+	err := resetSandboxDirectory(SPECIAL_JUDGE_DIR)
+	if err != nil {
+		return false, "", err
+	}
+	err = downloadFromStorage(ctx, filepath.Join(SPECIAL_JUDGE_DIR, lang.Filename), JUDGECODES_BUCKET_NAME, data.ProblemID)
+	if err != nil {
+		return false, "", err
+	}
+	compileResult, err := compile(ctx, lang, SPECIAL_JUDGE_DIR)
+	if err != nil {
+		return false, "", err
+	}
+	if !compileResult.compiled() {
+		log.Println("Special Judge Compile Error: " + compileResult.output)
+		return false, compileResult.output, nil
+	}
+	return true, "", nil
+}
+
+// watchShutdownSignal は SIGTERM か SIGINT を受け取ると取り消される context を返す
+func watchShutdownSignal() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %v, shutting down...", sig)
+		cancel()
+	}()
+	return ctx
+}
+
 func main() {
 	if err := verifySandbox(); err != nil {
 		log.Fatalln(err)
 	}
-	health()
+	ctx := watchShutdownSignal()
+	if len(os.Args) > 1 && os.Args[1] == "validate-languages" {
+		definitions, err := loadLanguageDefinition(LANGUAGE_DEFINITION_FILE)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		spJudgeDefinitons, err := loadSpecialJudgeLangs(SPECIAL_JUDGE_LANGS_FILE)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if !runLanguageValidation(ctx, definitions, spJudgeDefinitons) {
+			os.Exit(1)
+		}
+		return
+	}
 	session := session.New()
 	config := &aws.Config{Region: aws.String(AWS_REGION)}
 	judgeQueue = sqs.New(session, config)
 	storage = s3.New(session, config)
 	storageDownloader = s3manager.NewDownloader(session)
+	database = dynamodb.New(session, config)
 	signer = v4.NewSigner(session.Config.Credentials)
-	definitions, err := loadLanguageDefinition(LANGUAGE_DEFINITION_FILE)
+	definitionStore, err := newDefinitionStore(LANGUAGE_DEFINITION_FILE, SPECIAL_JUDGE_LANGS_FILE)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	spJudgeDefinitons, err := loadSpecialJudgeLangs(SPECIAL_JUDGE_LANGS_FILE)
-	if err != nil {
-		log.Fatalln(err)
+	definitionStore.watchReloadSignal()
+	jobs := newJobCanceller()
+	health(definitionStore)
+	startupDefinitions := definitionStore.current()
+	switch VALIDATE_LANGUAGES_ON_STARTUP {
+	case "warn":
+		runLanguageValidation(ctx, startupDefinitions.languages, startupDefinitions.spjudgelangs)
+	case "fatal":
+		if !runLanguageValidation(ctx, startupDefinitions.languages, startupDefinitions.spjudgelangs) {
+			log.Fatalln("language validation failed")
+		}
 	}
 	log.Println("Ready.")
-	for true {
+	for ctx.Err() == nil {
 		var err error
-		message, exist, err := receiveJudgeQueueMessage()
+		message, exist, err := receiveJudgeQueueMessage(ctx)
+		if ctx.Err() != nil {
+			break
+		}
 		if err != nil {
 			if message.message != nil {
 				deleteJudgeQueueMessage(message.message)
@@ -163,24 +337,49 @@ func main() {
 			continue
 		}
 		log.Println(message.data)
-		err = processCode(definitions, message.data, spJudgeDefinitons)
-		if err != nil {
+		definitionStore.reloadIfModified()
+		definitions := definitionStore.current()
+		jobCtx, finish := jobs.start(ctx, message.data, JOB_TIMEOUT)
+		err = processCode(jobCtx, definitions.languages, message.data, definitions.spjudgelangs)
+		canceled := jobCtx.Err() != nil
+		finish()
+		cancelReason := jobs.cancelReason()
+		if ctx.Err() != nil {
+			// 終了するときはメッセージを消さず、他のジャッジにやり直してもらう
+			log.Printf("Interrupted: %v", err)
+			break
+		}
+		if err != nil && cancelReason != "" {
+			// 問題の削除や再ジャッジで取り消したジョブは、結果を待っているものがないので報告しない
+			log.Printf("Canceled: %s", cancelReason)
+		} else if errors.Is(err, errTestcasesNotReady) {
+			// IE にはせず、生成が終わる頃まで見えなくして受信回数を使い切らないようにする。
+			// 生成のジョブは JOB_TIMEOUT で打ち切られるので、その間待てば生成は終わっている
 			log.Println(err)
-			if message.data.Type == "SUBMISSION" {
-				err = updateSubmission(message.data.SubmissionID, message.data.UserID, "IE", nil, nil)
-				if err != nil {
-					log.Println(err)
-				}
+			if err = delayJudgeQueueMessage(message.message, JOB_TIMEOUT); err != nil {
+				log.Println(err)
 			}
 			continue
+		} else if err != nil {
+			log.Println(err)
+			err = reportInternalError(ctx, message.data)
+			if err != nil {
+				log.Println(err)
+			}
+			// 取り消されたり時間切れになったりしたジョブはやり直さない
+			if !canceled {
+				continue
+			}
+		} else {
+			log.Println("Done!")
 		}
-		log.Println("Done!")
-		if message.data.Type == "PLAYGROUND" {
-			err = deleteFromStorage(PLAYGROUND_CODE_BUCKET_NAME, message.data.SessionID)
+		if message.data.Type == "PLAYGROUND" || message.data.Type == "SAMPLE_TEST" {
+			err = deleteFromStorage(ctx, PLAYGROUND_CODE_BUCKET_NAME, message.data.SessionID)
 			if err != nil {
 				log.Println(err)
 			}
 		}
 		deleteJudgeQueueMessage(message.message)
 	}
+	log.Println("Stopped.")
 }
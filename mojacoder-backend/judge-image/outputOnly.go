@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+var OUTPUT_ONLY_FILE = filepath.Join(TEMP_DIR, "output")
+var OUTPUT_ONLY_DIR = filepath.Join(TEMP_DIR, "outputs")
+
+// prepareOutputOnlyAnswer は提出された出力をダウンロードする。
+// zip であればテストケース名ごとの出力として展開し、展開できなければ利用者に返すメッセージとともに false を返す。
+func prepareOutputOnlyAnswer(ctx context.Context, submissionID string) (bool, string, error) {
+	const errorMessage = "failed to prepare an output: %v"
+	err := downloadFromStorage(ctx, OUTPUT_ONLY_FILE, SUBMITTED_CODE_BUCKET_NAME, submissionID)
+	if err != nil {
+		return false, "", fmt.Errorf(errorMessage, err)
+	}
+	isArchive, err := normalizeSubmittedArchive(OUTPUT_ONLY_FILE)
+	if err != nil {
+		return false, "", fmt.Errorf(errorMessage, err)
+	}
+	if !isArchive {
+		return true, "", nil
+	}
+	err = unzipSubmission(OUTPUT_ONLY_FILE, OUTPUT_ONLY_DIR)
+	if errors.Is(err, errInvalidArchive) {
+		return false, err.Error(), nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf(errorMessage, err)
+	}
+	return true, "", os.Remove(OUTPUT_ONLY_FILE)
+}
+
+// readOutputOnlyAnswer はテストケースに対する提出出力を書き出す。
+// 単一ファイルの提出はすべてのテストケースの出力とみなし、zip に含まれないテストケースは空の出力とする。
+func readOutputOnlyAnswer(testcaseName string, stdout io.Writer) error {
+	path := OUTPUT_ONLY_FILE
+	if _, err := os.Stat(OUTPUT_ONLY_DIR); err == nil {
+		path = filepath.Join(OUTPUT_ONLY_DIR, testcaseName)
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		log.Printf("Output for %s not found", testcaseName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(stdout, file)
+	return err
+}
+
+func judgeOutputOnly(ctx context.Context, data JudgeQueueData, problem ProblemSetting) error {
+	log.Printf("Downloading output for submission: %s", data.SubmissionID)
+	prepared, message, err := prepareOutputOnlyAnswer(ctx, data.SubmissionID)
+	if err != nil {
+		return err
+	}
+	if !prepared {
+		return updateSubmission(ctx, data.SubmissionID, data.UserID, "CE", &message, nil, nil)
+	}
+	prepared, judgeCompileOutput, err := prepareJudgeProgram(ctx, data, problem.judgeType)
+	if err != nil {
+		return err
+	}
+	if !prepared {
+		return updateSubmission(ctx, data.SubmissionID, data.UserID, "JCE", nil, nil, &judgeCompileOutput)
+	}
+	err = updateSubmission(ctx, data.SubmissionID, data.UserID, "WJ", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return judge(ctx, LanguageDefinition{}, data, problem)
+}
@@ -1,29 +1,114 @@
 package main
 
-import "strings"
+import (
+	"context"
+	"fmt"
+	"strings"
+)
 
 const PLAYGROUND_TIME_LIMIT = 2
-const PLAYGROUND_MEMORY_LIMIT = 131072 // 128 MB
+const PLAYGROUND_MEMORY_LIMIT = 131072      // 128 MB
+const PLAYGROUND_MAX_TIME_LIMIT = 10        // 秒, 言語ごとの倍率を掛けた後の上限
+const PLAYGROUND_MAX_MEMORY_LIMIT = 1048576 // 1 GB
+const PLAYGROUND_MAX_INPUTS = 10
+const PLAYGROUND_OUTPUT_LIMIT = 64 * 1024 // bytes
 
-func testCode(definition LanguageDefinition, data JudgeQueueData) error {
-	var err error
-	var stdout, stderr strings.Builder
-	config := RunConfig{
-		stdin:          strings.NewReader(data.Stdin),
-		stdout:         &stdout,
-		stderr:         &stderr,
-		timeLimit:      PLAYGROUND_TIME_LIMIT,
-		memoryLimit:    PLAYGROUND_MEMORY_LIMIT,
-		dir:            TEMP_DIR,
-		runCommandArgs: []string{},
-	}
-	result, err := run(definition, config)
-	if err != nil {
-		return err
-	}
-	err = responsePlayground(data.SessionID, data.UserID, result.exitCode, result.time, result.memory, stdout.String(), stderr.String())
-	if err != nil {
-		return err
+// playgroundInputs は stdins が与えられていればそれを、なければ従来どおり stdin だけを返す。
+// 入力が多すぎる場合は実行せず、利用者に返すメッセージを返す
+func playgroundInputs(data JudgeQueueData) ([]string, string) {
+	if len(data.Stdins) == 0 {
+		return []string{data.Stdin}, ""
+	}
+	if len(data.Stdins) > PLAYGROUND_MAX_INPUTS {
+		return nil, fmt.Sprintf("Too many inputs (at most %d).", PLAYGROUND_MAX_INPUTS)
+	}
+	return data.Stdins, ""
+}
+
+// playgroundLimits は指定された制限をサーバーの上限に収める。指定がなければ既定値を使う。
+// 実行時間制限は run で言語ごとの倍率が掛かるので、掛けた後に上限を超えないようにする
+func playgroundLimits(data JudgeQueueData, definition LanguageDefinition) (int, int) {
+	timeLimit, memoryLimit := PLAYGROUND_TIME_LIMIT, PLAYGROUND_MEMORY_LIMIT
+	if data.TimeLimit > 0 {
+		timeLimit = data.TimeLimit
+	}
+	maxTimeLimit := int(PLAYGROUND_MAX_TIME_LIMIT / definition.timeLimitMultiplier())
+	if maxTimeLimit < 1 {
+		maxTimeLimit = 1
+	}
+	if timeLimit > maxTimeLimit {
+		timeLimit = maxTimeLimit
+	}
+	if data.MemoryLimit > 0 {
+		memoryLimit = data.MemoryLimit
+	}
+	if memoryLimit > PLAYGROUND_MAX_MEMORY_LIMIT {
+		memoryLimit = PLAYGROUND_MAX_MEMORY_LIMIT
+	}
+	return timeLimit, memoryLimit
+}
+
+// playgroundStatus は実行結果をプレイグラウンドの状態に変換する
+func playgroundStatus(result RunResult, outputLimitExceeded bool) string {
+	switch {
+	case result.status == RunResultStatusTimeLimitExceeded:
+		return "TLE"
+	case result.status == RunResultStatusMemoryLimitExceeded:
+		return "MLE"
+	case outputLimitExceeded:
+		return "OLE"
+	case result.status == RunResultStatusRunTimeError:
+		return "RE"
+	}
+	return "OK"
+}
+
+func newPlaygroundRunResponse(data JudgeQueueData, index int, result RunResult, stdout, stderr *limitedWriter, compileOutput string) ResponsePlaygroundInput {
+	response := ResponsePlaygroundInput{
+		SessionID:     data.SessionID,
+		UserID:        data.UserID,
+		Index:         &index,
+		Status:        playgroundStatus(result, stdout.truncated),
+		ExitCode:      &result.exitCode,
+		Time:          &result.time,
+		Memory:        &result.memory,
+		Stdout:        stdout.String(),
+		Stderr:        stderr.String(),
+		CompileOutput: compileOutput,
+	}
+	if result.signal != 0 {
+		response.Signal = &result.signal
+	}
+	return response
+}
+
+// testCode はコンパイル済みのコードを入力ごとに実行し、結果を 1 件ずつ返す
+func testCode(ctx context.Context, definition LanguageDefinition, data JudgeQueueData, compileOutput string) error {
+	inputs, message := playgroundInputs(data)
+	if message != "" {
+		return responsePlaygroundError(ctx, data, "IE", message)
+	}
+	timeLimit, memoryLimit := playgroundLimits(data, definition)
+	for i, stdin := range inputs {
+		stdout := newLimitedWriter(PLAYGROUND_OUTPUT_LIMIT)
+		stderr := newLimitedWriter(PLAYGROUND_OUTPUT_LIMIT)
+		config := RunConfig{
+			stdin:          strings.NewReader(stdin),
+			stdout:         stdout,
+			stderr:         stderr,
+			timeLimit:      timeLimit,
+			memoryLimit:    memoryLimit,
+			dir:            TEMP_DIR,
+			runCommandArgs: []string{},
+		}
+		result, err := run(ctx, definition, config)
+		if err != nil {
+			return err
+		}
+		err = responsePlayground(ctx, newPlaygroundRunResponse(data, i, result, stdout, stderr, compileOutput))
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
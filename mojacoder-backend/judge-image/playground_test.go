@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPlaygroundInputs(t *testing.T) {
+	if inputs, message := playgroundInputs(JudgeQueueData{Stdin: "1"}); len(inputs) != 1 || inputs[0] != "1" || message != "" {
+		t.Errorf("stdin must be used when stdins is empty: %v, %q", inputs, message)
+	}
+	if inputs, message := playgroundInputs(JudgeQueueData{Stdin: "1", Stdins: []string{"2", "3"}}); len(inputs) != 2 || inputs[0] != "2" || message != "" {
+		t.Errorf("stdins must be used: %v, %q", inputs, message)
+	}
+	if inputs, message := playgroundInputs(JudgeQueueData{Stdins: make([]string, PLAYGROUND_MAX_INPUTS+1)}); inputs != nil || message == "" {
+		t.Errorf("too many inputs must be rejected: %d, %q", len(inputs), message)
+	}
+}
+
+func TestPlaygroundLimits(t *testing.T) {
+	if timeLimit, memoryLimit := playgroundLimits(JudgeQueueData{}, LanguageDefinition{}); timeLimit != PLAYGROUND_TIME_LIMIT || memoryLimit != PLAYGROUND_MEMORY_LIMIT {
+		t.Errorf("default limits are not applied: %d, %d", timeLimit, memoryLimit)
+	}
+	if timeLimit, memoryLimit := playgroundLimits(JudgeQueueData{TimeLimit: 5, MemoryLimit: 262144}, LanguageDefinition{}); timeLimit != 5 || memoryLimit != 262144 {
+		t.Errorf("custom limits are not applied: %d, %d", timeLimit, memoryLimit)
+	}
+	if timeLimit, memoryLimit := playgroundLimits(JudgeQueueData{TimeLimit: 100, MemoryLimit: 1 << 30}, LanguageDefinition{}); timeLimit != PLAYGROUND_MAX_TIME_LIMIT || memoryLimit != PLAYGROUND_MAX_MEMORY_LIMIT {
+		t.Errorf("limits must be capped: %d, %d", timeLimit, memoryLimit)
+	}
+	// 倍率を掛けた後の実行時間も上限に収める
+	python := LanguageDefinition{TimeLimitMultiplier: 3}
+	if timeLimit, _ := playgroundLimits(JudgeQueueData{TimeLimit: 10}, python); python.timeLimitMillis(timeLimit) > PLAYGROUND_MAX_TIME_LIMIT*1000 {
+		t.Errorf("time limit with multiplier exceeds the cap: %d ms", python.timeLimitMillis(timeLimit))
+	}
+	if timeLimit, _ := playgroundLimits(JudgeQueueData{TimeLimit: 10}, LanguageDefinition{TimeLimitMultiplier: 20}); timeLimit != 1 {
+		t.Errorf("time limit must be at least 1 second: %d", timeLimit)
+	}
+}
+
+func TestPlaygroundStatus(t *testing.T) {
+	tests := []struct {
+		result              RunResult
+		outputLimitExceeded bool
+		expected            string
+	}{
+		{RunResult{status: RunResultStatusSuccess}, false, "OK"},
+		{RunResult{status: RunResultStatusTimeLimitExceeded}, true, "TLE"},
+		{RunResult{status: RunResultStatusMemoryLimitExceeded}, false, "MLE"},
+		{RunResult{status: RunResultStatusRunTimeError}, true, "OLE"},
+		{RunResult{status: RunResultStatusRunTimeError, exitCode: 139, signal: 11}, false, "RE"},
+	}
+	for _, test := range tests {
+		if status := playgroundStatus(test.result, test.outputLimitExceeded); status != test.expected {
+			t.Errorf("playgroundStatus(%v, %v) = %s; expected %s", test.result, test.outputLimitExceeded, status, test.expected)
+		}
+	}
+}
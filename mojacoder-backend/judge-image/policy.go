@@ -0,0 +1,93 @@
+package main
+
+import "fmt"
+
+const (
+	JUDGE_POLICY_STOP_ON_TLE      = "STOP_ON_TLE"
+	JUDGE_POLICY_RUN_ALL          = "RUN_ALL"
+	JUDGE_POLICY_STOP_ON_FAILURE  = "STOP_ON_FAILURE"
+	JUDGE_POLICY_STOP_PER_SUBTASK = "STOP_PER_SUBTASK"
+)
+
+type Subtask struct {
+	Name      string   `json:"name"`
+	Testcases []string `json:"testcases"`
+}
+
+func validateJudgePolicy(policy string, subtasks []Subtask) (string, error) {
+	switch policy {
+	case "":
+		return JUDGE_POLICY_STOP_ON_TLE, nil
+	case JUDGE_POLICY_STOP_ON_TLE, JUDGE_POLICY_RUN_ALL, JUDGE_POLICY_STOP_ON_FAILURE:
+		return policy, nil
+	case JUDGE_POLICY_STOP_PER_SUBTASK:
+		if len(subtasks) == 0 {
+			return policy, fmt.Errorf("%s requires subtasks", policy)
+		}
+		return policy, nil
+	}
+	return policy, fmt.Errorf("unknown judgePolicy '%s'", policy)
+}
+
+// testcaseSkipper は判定方針に従い、どのテストケースを飛ばすかを決める
+type testcaseSkipper struct {
+	policy         string
+	subtasks       []Subtask
+	stopped        bool
+	failedSubtasks map[string]bool
+}
+
+func newTestcaseSkipper(problem ProblemSetting) *testcaseSkipper {
+	return &testcaseSkipper{policy: problem.judgePolicy, subtasks: problem.subtasks, failedSubtasks: map[string]bool{}}
+}
+
+func (s *testcaseSkipper) subtasksOf(name string) []string {
+	names := []string{}
+	for _, subtask := range s.subtasks {
+		for _, testcase := range subtask.Testcases {
+			if testcase == name {
+				names = append(names, subtask.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// skip はテストケースを判定せずに SKIPPED とするかを返す
+func (s *testcaseSkipper) skip(name string) bool {
+	if s.stopped {
+		return true
+	}
+	if s.policy != JUDGE_POLICY_STOP_PER_SUBTASK {
+		return false
+	}
+	// どの小課題にも属さないテストケースは常に判定する。複数の小課題に属する場合は、すべてが失敗済みのときだけ飛ばす
+	subtasks := s.subtasksOf(name)
+	if len(subtasks) == 0 {
+		return false
+	}
+	for _, subtask := range subtasks {
+		if !s.failedSubtasks[subtask] {
+			return false
+		}
+	}
+	return true
+}
+
+// record は判定結果を記録する
+func (s *testcaseSkipper) record(judgement TestcaseJudgement) {
+	failed := judgement.testcase.Status != "AC"
+	switch s.policy {
+	case JUDGE_POLICY_STOP_ON_TLE:
+		s.stopped = s.stopped || judgement.stop
+	case JUDGE_POLICY_STOP_ON_FAILURE:
+		s.stopped = s.stopped || failed
+	case JUDGE_POLICY_STOP_PER_SUBTASK:
+		if failed {
+			for _, subtask := range s.subtasksOf(judgement.testcase.Name) {
+				s.failedSubtasks[subtask] = true
+			}
+		}
+	}
+}
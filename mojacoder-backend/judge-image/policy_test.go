@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+)
+
+func judgeWithSkipper(skipper *testcaseSkipper, results map[string]string, stops map[string]bool, names []string) []string {
+	statuses := []string{}
+	for _, name := range names {
+		if skipper.skip(name) {
+			statuses = append(statuses, "SKIPPED")
+			continue
+		}
+		statuses = append(statuses, results[name])
+		skipper.record(TestcaseJudgement{testcase: TestcaseResultInput{Name: name, Status: results[name]}, stop: stops[name]})
+	}
+	return statuses
+}
+
+func TestTestcaseSkipper(t *testing.T) {
+	names := []string{"a", "b", "c", "d"}
+	results := map[string]string{"a": "WA", "b": "TLE", "c": "AC", "d": "AC"}
+	stops := map[string]bool{"b": true}
+	subtasks := []Subtask{{"1", []string{"a", "b"}}, {"2", []string{"b", "c"}}}
+	tests := []struct {
+		policy   string
+		expected []string
+	}{
+		{JUDGE_POLICY_STOP_ON_TLE, []string{"WA", "TLE", "SKIPPED", "SKIPPED"}},
+		{JUDGE_POLICY_RUN_ALL, []string{"WA", "TLE", "AC", "AC"}},
+		{JUDGE_POLICY_STOP_ON_FAILURE, []string{"WA", "SKIPPED", "SKIPPED", "SKIPPED"}},
+		// b は小課題 2 にも属するので判定され、その失敗で c も飛ばされる。d はどの小課題にも属さない
+		{JUDGE_POLICY_STOP_PER_SUBTASK, []string{"WA", "TLE", "SKIPPED", "AC"}},
+	}
+	for _, test := range tests {
+		skipper := newTestcaseSkipper(ProblemSetting{judgePolicy: test.policy, subtasks: subtasks})
+		statuses := judgeWithSkipper(skipper, results, stops, names)
+		for i := range statuses {
+			if statuses[i] != test.expected[i] {
+				t.Errorf("%s: statuses = %v; expected %v", test.policy, statuses, test.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestValidateJudgePolicy(t *testing.T) {
+	if policy, err := validateJudgePolicy("", nil); err != nil || policy != JUDGE_POLICY_STOP_ON_TLE {
+		t.Errorf("default policy = %s, %v", policy, err)
+	}
+	if _, err := validateJudgePolicy(JUDGE_POLICY_STOP_PER_SUBTASK, nil); err == nil {
+		t.Error("STOP_PER_SUBTASK without subtasks must be rejected")
+	}
+	if _, err := validateJudgePolicy("UNKNOWN", nil); err == nil {
+		t.Error("unknown policy must be rejected")
+	}
+}
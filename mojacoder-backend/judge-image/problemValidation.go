@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const SOLUTIONS_MANIFEST_PATH = "/tmp/mojacoder-solutions.json"
+
+// 検証の結果は問題の項目に保存されるので、DynamoDB の項目の上限 (400 KB) に収まるようメッセージを切り詰める
+const VALIDATION_MESSAGE_LIMIT = 4 * 1024 // bytes
+
+// WRONG_SOLUTION_VERDICTS は FAIL を期待する解答が出してよい結果。CE やジャッジ側の異常は問題の不備として扱う
+var WRONG_SOLUTION_VERDICTS = []string{"WA", "TLE", "MLE", "RE"}
+
+// ReferenceSolution は問題の作者が用意した解答で、一覧は problemID/solutions.json に置かれる
+type ReferenceSolution struct {
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Expected string `json:"expected"` // AC, WA, TLE, MLE, RE または FAIL (AC 以外なら何でもよい)
+	Key      string `json:"key"`
+}
+
+type SolutionValidation struct {
+	Name        string  `json:"name"`
+	Lang        string  `json:"lang"`
+	Expected    string  `json:"expected"`
+	Verdict     string  `json:"verdict"`
+	Passed      bool    `json:"passed"`
+	MaxTime     *int    `json:"maxTime"`
+	MaxMemory   *int    `json:"maxMemory"`
+	TimeLimit   int     `json:"timeLimit"`   // ms
+	MemoryLimit int     `json:"memoryLimit"` // KB
+	Message     *string `json:"message"`
+	PassedCount int     `json:"passedCount"`
+}
+
+type ProblemValidation struct {
+	Status    string               `json:"status"`
+	Datetime  string               `json:"datetime"`
+	Message   *string              `json:"message"`
+	Testcases []TestcaseValidation `json:"testcases"`
+	Solutions []SolutionValidation `json:"solutions"`
+}
+
+// truncateValidationMessage はメッセージを VALIDATION_MESSAGE_LIMIT に収める
+func truncateValidationMessage(message string) string {
+	writer := newLimitedWriter(VALIDATION_MESSAGE_LIMIT)
+	writer.Write([]byte(message))
+	return writer.String()
+}
+
+// newProblemValidation は保存する大きさを抑えるため、テストケースは制約を満たさなかったものを
+// MAX_REPORTED_INVALID_TESTCASES 個まで残す
+func newProblemValidation(status string, message string, testcases []TestcaseValidation, solutions []SolutionValidation) ProblemValidation {
+	validation := ProblemValidation{Status: status, Datetime: time.Now().UTC().Format(time.RFC3339), Testcases: []TestcaseValidation{}, Solutions: solutions}
+	if message != "" {
+		message = truncateValidationMessage(message)
+		validation.Message = &message
+	}
+	for _, testcase := range testcases {
+		if !testcase.Valid && len(validation.Testcases) < MAX_REPORTED_INVALID_TESTCASES {
+			validation.Testcases = append(validation.Testcases, testcase)
+		}
+	}
+	if validation.Solutions == nil {
+		validation.Solutions = []SolutionValidation{}
+	}
+	return validation
+}
+
+func updateProblemValidation(ctx context.Context, problemID string, validation ProblemValidation) error {
+	variables := make(map[string]interface{})
+	query := `
+		mutation UpdateProblemValidation($input: UpdateProblemValidationInput!) {
+			updateProblemValidation(input: $input) {
+				status
+			}
+		}
+	`
+	variables["input"] = map[string]interface{}{"problemID": problemID, "validation": validation}
+	return requestGraphql(ctx, query, variables, nil)
+}
+
+// solutionPassed は解答の結果が作者の期待どおりかを返す
+func solutionPassed(expected, verdict string) bool {
+	if expected != "FAIL" {
+		return verdict == expected
+	}
+	for _, wrong := range WRONG_SOLUTION_VERDICTS {
+		if verdict == wrong {
+			return true
+		}
+	}
+	return false
+}
+
+// validationStatus はすべての解答が期待どおりで、正解の解答が 1 つ以上あれば PASSED を返す
+func validationStatus(solutions []SolutionValidation) (string, string) {
+	accepted := false
+	for _, solution := range solutions {
+		if !solution.Passed {
+			return "FAILED", fmt.Sprintf("%s: expected %s but got %s.", solution.Name, solution.Expected, solution.Verdict)
+		}
+		accepted = accepted || solution.Expected == "AC"
+	}
+	if !accepted {
+		return "FAILED", "No solution is expected to be accepted."
+	}
+	return "PASSED", ""
+}
+
+func downloadReferenceSolutions(ctx context.Context, problemID string) ([]ReferenceSolution, error) {
+	solutions := []ReferenceSolution{}
+	key := path.Join(problemID, "solutions.json")
+	exist, err := existsInStorage(ctx, JUDGECODES_BUCKET_NAME, key)
+	if err != nil || !exist {
+		return solutions, err
+	}
+	if err = downloadFromStorage(ctx, SOLUTIONS_MANIFEST_PATH, JUDGECODES_BUCKET_NAME, key); err != nil {
+		return solutions, err
+	}
+	data, err := ioutil.ReadFile(SOLUTIONS_MANIFEST_PATH)
+	if err != nil {
+		return solutions, err
+	}
+	err = json.Unmarshal(data, &solutions)
+	return solutions, err
+}
+
+// validateSolution は解答をコンパイルし、ジャッジの方針によらずすべてのテストケースで判定する
+func validateSolution(ctx context.Context, definitions map[string]LanguageDefinition, data JudgeQueueData, problem ProblemSetting, solution ReferenceSolution) (SolutionValidation, error) {
+	result := SolutionValidation{Name: solution.Name, Lang: solution.Lang, Expected: solution.Expected}
+	finish := func(verdict, message string) (SolutionValidation, error) {
+		result.Verdict = verdict
+		result.Passed = solutionPassed(solution.Expected, verdict)
+		if message != "" {
+			message = truncateValidationMessage(message)
+			result.Message = &message
+		}
+		return result, nil
+	}
+	definition, exist := definitions[solution.Lang]
+	if !exist {
+		return finish("CE", fmt.Sprintf("Language not found: %s", solution.Lang))
+	}
+	result.TimeLimit = definition.timeLimitMillis(JUDGE_TIME_LIMIT)
+	result.MemoryLimit = JUDGE_MEMORY_LIMIT + definition.AdditionalMemory
+	if err := resetSandboxDirectory(TEMP_DIR); err != nil {
+		return result, err
+	}
+	var sources []string
+	if problem.problemType == PROBLEM_TYPE_GRADER {
+		prepared, err := prepareGrader(ctx, data.ProblemID, solution.Lang, definition)
+		if err != nil {
+			return result, err
+		}
+		if !prepared {
+			return finish("CE", fmt.Sprintf("This problem does not support %s.", solution.Lang))
+		}
+		definition = definition.withGrader()
+		sources = []string{definition.GraderFilename, definition.Filename}
+	}
+	if err := downloadFromStorage(ctx, filepath.Join(TEMP_DIR, definition.Filename), JUDGECODES_BUCKET_NAME, solution.Key); err != nil {
+		return result, err
+	}
+	compileResult, err := compile(ctx, definition, TEMP_DIR, sources...)
+	if err != nil {
+		return result, err
+	}
+	if !compileResult.compiled() {
+		return finish(compileResult.verdict(), compileResult.output)
+	}
+	testcasesPath, names, err := downloadTestcases(ctx, data.ProblemID)
+	if err != nil {
+		return result, err
+	}
+	judgeLog := newLimitedWriter(SPECIAL_JUDGE_LOG_LIMIT)
+	testcases := []TestcaseResultInput{}
+	for _, name := range names {
+		var stdout strings.Builder
+		judgement, err := judgeTestcase(ctx, definition, problem, testcasesPath, name, &stdout, nil, judgeLog)
+		if err != nil {
+			return result, err
+		}
+		testcases = append(testcases, judgement.testcase)
+	}
+	summary, err := summarizeTestcases(testcases)
+	if err != nil {
+		return finish("IE", err.Error())
+	}
+	result.MaxTime = &summary.MaxTime
+	result.MaxMemory = &summary.MaxMemory
+	result.PassedCount = summary.PassedCount
+	return finish(summary.Verdict, judgeLog.String())
+}
+
+// validateProblem は入力検証プログラムでテストケースを確かめ、作者の解答をすべて判定して、
+// テストケースとジャッジプログラムが期待どおりに動くかを問題に記録する
+func validateProblem(ctx context.Context, definitions map[string]LanguageDefinition, spjudgelangs map[string]SpecialJudgeLang, data JudgeQueueData) error {
+	const errorMessage = "failed to validate a problem: %v"
+	testcases := []TestcaseValidation{}
+	results := []SolutionValidation{}
+	report := func(status, message string) error {
+		err := updateProblemValidation(ctx, data.ProblemID, newProblemValidation(status, message, testcases, results))
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+		return nil
+	}
+	if err := report("VALIDATING", ""); err != nil {
+		return err
+	}
+	problem, err := getProblemSetting(ctx, data.ProblemID, spjudgelangs, definitions)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	if !problem.testcasesReady {
+		return report("FAILED", "Testcases are not generated yet.")
+	}
+	// 入力が制約を満たさなくても解答の判定は続け、最初に見つかった問題を報告する
+	testcases, validatorMessage, err := validateTestcases(ctx, definitions, spjudgelangs, data, problem)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	if problem.problemType == PROBLEM_TYPE_OUTPUT_ONLY {
+		if problem.validatorLang == "" {
+			return report("FAILED", "Output only problems can't be validated with solutions.")
+		}
+		if validatorMessage != "" {
+			return report("FAILED", validatorMessage)
+		}
+		return report("PASSED", "")
+	}
+	if len(testcases) > 0 {
+		if err = report("VALIDATING", ""); err != nil {
+			return err
+		}
+	}
+	solutions, err := downloadReferenceSolutions(ctx, data.ProblemID)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	if len(solutions) == 0 {
+		if validatorMessage != "" {
+			return report("FAILED", validatorMessage)
+		}
+		return report("FAILED", "No reference solutions.")
+	}
+	prepared, judgeCompileOutput, err := prepareJudgeProgram(ctx, data, problem.judgeType)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	if !prepared {
+		return report("FAILED", "Judge code compile error:\n"+judgeCompileOutput)
+	}
+	for _, solution := range solutions {
+		log.Printf("Validating solution %s (%s)...", solution.Name, solution.Lang)
+		result, err := validateSolution(ctx, definitions, data, problem, solution)
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+		log.Printf("%s: %s (expected %s)", solution.Name, result.Verdict, solution.Expected)
+		results = append(results, result)
+		if err = report("VALIDATING", ""); err != nil {
+			return err
+		}
+	}
+	status, message := validationStatus(results)
+	if validatorMessage != "" {
+		status, message = "FAILED", validatorMessage
+	}
+	return report(status, message)
+}
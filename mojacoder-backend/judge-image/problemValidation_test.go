@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSolutionPassed(t *testing.T) {
+	tests := []struct {
+		expected, verdict string
+		want              bool
+	}{
+		{"AC", "AC", true},
+		{"AC", "WA", false},
+		{"TLE", "TLE", true},
+		{"TLE", "WA", false},
+		{"FAIL", "WA", true},
+		{"FAIL", "RE", true},
+		{"FAIL", "AC", false},
+		{"FAIL", "CE", false},
+		{"FAIL", "JRE", false},
+	}
+	for _, test := range tests {
+		if got := solutionPassed(test.expected, test.verdict); got != test.want {
+			t.Errorf("solutionPassed(%s, %s) = %v, want %v", test.expected, test.verdict, got, test.want)
+		}
+	}
+}
+
+func TestValidationStatus(t *testing.T) {
+	accepted := SolutionValidation{Name: "main.cpp", Expected: "AC", Verdict: "AC", Passed: true}
+	wrong := SolutionValidation{Name: "wa.cpp", Expected: "FAIL", Verdict: "WA", Passed: true}
+	if status, message := validationStatus([]SolutionValidation{accepted, wrong}); status != "PASSED" || message != "" {
+		t.Errorf("unexpected status: %s %s", status, message)
+	}
+	if status, _ := validationStatus([]SolutionValidation{wrong}); status != "FAILED" {
+		t.Errorf("validation without accepted solutions must fail: %s", status)
+	}
+	wrong.Verdict, wrong.Passed = "AC", false
+	status, message := validationStatus([]SolutionValidation{accepted, wrong})
+	if status != "FAILED" || !strings.Contains(message, "wa.cpp") {
+		t.Errorf("unexpected status: %s %s", status, message)
+	}
+}
+
+func TestNewProblemValidationJSON(t *testing.T) {
+	data, err := json.Marshal(newProblemValidation("VALIDATING", "", nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if solutions, ok := decoded["solutions"].([]interface{}); !ok || len(solutions) != 0 {
+		t.Errorf("solutions must be an empty list: %s", data)
+	}
+	if decoded["message"] != nil || decoded["status"] != "VALIDATING" {
+		t.Errorf("unexpected validation: %s", data)
+	}
+}
+
+func TestNewProblemValidationIsBounded(t *testing.T) {
+	message := strings.Repeat("x", VALIDATION_MESSAGE_LIMIT*2)
+	testcases := []TestcaseValidation{{Name: "valid", Valid: true}}
+	for i := 0; i < MAX_REPORTED_INVALID_TESTCASES+3; i++ {
+		testcases = append(testcases, TestcaseValidation{Name: "invalid", Valid: false})
+	}
+	validation := newProblemValidation("FAILED", message, testcases, nil)
+	if len(*validation.Message) != VALIDATION_MESSAGE_LIMIT+len("\n(truncated)") {
+		t.Errorf("message is not truncated: %d bytes", len(*validation.Message))
+	}
+	if len(validation.Testcases) != MAX_REPORTED_INVALID_TESTCASES {
+		t.Errorf("%d testcases are reported", len(validation.Testcases))
+	}
+	for _, testcase := range validation.Testcases {
+		if testcase.Valid {
+			t.Error("valid testcases must not be reported")
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import "time"
+
+// 途中経過は最後に送ってから PROGRESS_UPDATE_INTERVAL 経つか、PROGRESS_UPDATE_CASES 件たまったときだけ送る
+const PROGRESS_UPDATE_INTERVAL = time.Second
+const PROGRESS_UPDATE_CASES = 10
+
+// progressReporter はテストケースごとの途中経過をまとめて送る。最終状態は呼び出し側が必ず送る
+type progressReporter struct {
+	send     func() error
+	interval time.Duration
+	cases    int
+	now      func() time.Time
+	lastSent time.Time
+	pending  int
+}
+
+func newProgressReporter(send func() error) *progressReporter {
+	return &progressReporter{send: send, interval: PROGRESS_UPDATE_INTERVAL, cases: PROGRESS_UPDATE_CASES, now: time.Now, lastSent: time.Now()}
+}
+
+// update は途中経過が 1 件進んだことを記録し、必要なら送る
+func (r *progressReporter) update() error {
+	r.pending++
+	if r.pending < r.cases && r.now().Sub(r.lastSent) < r.interval {
+		return nil
+	}
+	r.pending = 0
+	r.lastSent = r.now()
+	return r.send()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressReporter(t *testing.T) {
+	sent := 0
+	now := time.Unix(0, 0)
+	reporter := &progressReporter{
+		send:     func() error { sent++; return nil },
+		interval: time.Second,
+		cases:    3,
+		now:      func() time.Time { return now },
+		lastSent: now,
+	}
+	reporter.update()
+	reporter.update()
+	if sent != 0 {
+		t.Fatalf("updates must be coalesced: sent %d", sent)
+	}
+	reporter.update()
+	if sent != 1 {
+		t.Fatalf("update must be sent after %d cases: sent %d", reporter.cases, sent)
+	}
+	now = now.Add(2 * time.Second)
+	reporter.update()
+	if sent != 2 {
+		t.Fatalf("update must be sent after the interval: sent %d", sent)
+	}
+}
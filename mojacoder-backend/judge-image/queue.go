@@ -1,20 +1,29 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/sqs"
 )
 
 type JudgeQueueData struct {
-	Type         string `json:"type"`
-	SessionID    string `json:"sessionID"`
-	SubmissionID string `json:"submissionID"`
-	UserID       string `json:"userID"`
-	Lang         string `json:"Lang"`
-	Stdin        string `json:"stdin"`
-	ProblemID    string `json:"problemID"`
+	Type         string   `json:"type"`
+	SessionID    string   `json:"sessionID"`
+	SubmissionID string   `json:"submissionID"`
+	UserID       string   `json:"userID"`
+	Lang         string   `json:"Lang"`
+	Stdin        string   `json:"stdin"`
+	ProblemID    string   `json:"problemID"`
+	Stdins       []string `json:"stdins"`              // PLAYGROUND のみ
+	TimeLimit    int      `json:"timeLimit"`           // 秒, 0 なら既定値
+	MemoryLimit  int      `json:"memoryLimit"`         // KB, 0 なら既定値
+	Testcases    []string `json:"testcases,omitempty"` // REJUDGE のみ。空ならすべてのテストケース
+	MessageID    string   `json:"-"`                   // 受け取った SQS メッセージの ID
 }
 
 type JudgeQueueMessage struct {
@@ -26,10 +35,10 @@ const JUDGEQUEUE_WAIT_TIMEOUT = 20
 
 var judgeQueue *sqs.SQS
 
-func receiveJudgeQueueMessage() (JudgeQueueMessage, bool, error) {
+func receiveJudgeQueueMessage(ctx context.Context) (JudgeQueueMessage, bool, error) {
 	var message JudgeQueueMessage
 	var err error
-	res, err := judgeQueue.ReceiveMessage(&sqs.ReceiveMessageInput{
+	res, err := judgeQueue.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
 		QueueUrl:            aws.String(JUDGEQUEUE_URL),
 		MaxNumberOfMessages: aws.Int64(1),
 		WaitTimeSeconds:     aws.Int64(JUDGEQUEUE_WAIT_TIMEOUT),
@@ -45,6 +54,7 @@ func receiveJudgeQueueMessage() (JudgeQueueMessage, bool, error) {
 	if err != nil {
 		return message, false, err
 	}
+	message.data.MessageID = aws.StringValue(message.message.MessageId)
 	return message, true, nil
 }
 
@@ -55,3 +65,54 @@ func deleteJudgeQueueMessage(message *sqs.Message) error {
 	})
 	return err
 }
+
+// JUDGEQUEUE_MAX_VISIBILITY_TIMEOUT は SQS で指定できる可視性タイムアウトの上限
+const JUDGEQUEUE_MAX_VISIBILITY_TIMEOUT = 12 * time.Hour
+
+// delayJudgeQueueMessage はメッセージを消さずに delay の間だけ見えなくし、その後にやり直させる
+func delayJudgeQueueMessage(message *sqs.Message, delay time.Duration) error {
+	if delay > JUDGEQUEUE_MAX_VISIBILITY_TIMEOUT {
+		delay = JUDGEQUEUE_MAX_VISIBILITY_TIMEOUT
+	}
+	_, err := judgeQueue.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(JUDGEQUEUE_URL),
+		ReceiptHandle:     aws.String(*message.ReceiptHandle),
+		VisibilityTimeout: aws.Int64(int64(delay / time.Second)),
+	})
+	return err
+}
+
+const JUDGEQUEUE_BATCH_SIZE = 10
+
+// sendJudgeQueueMessages はジョブを SendMessageBatch の上限ごとに分けて積む
+func sendJudgeQueueMessages(ctx context.Context, data []JudgeQueueData) error {
+	const errorMessage = "failed to send judge queue messages: %v"
+	for start := 0; start < len(data); start += JUDGEQUEUE_BATCH_SIZE {
+		end := start + JUDGEQUEUE_BATCH_SIZE
+		if end > len(data) {
+			end = len(data)
+		}
+		entries := make([]*sqs.SendMessageBatchRequestEntry, 0, end-start)
+		for i, item := range data[start:end] {
+			body, err := json.Marshal(item)
+			if err != nil {
+				return fmt.Errorf(errorMessage, err)
+			}
+			entries = append(entries, &sqs.SendMessageBatchRequestEntry{
+				Id:          aws.String(strconv.Itoa(i)),
+				MessageBody: aws.String(string(body)),
+			})
+		}
+		res, err := judgeQueue.SendMessageBatchWithContext(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(JUDGEQUEUE_URL),
+			Entries:  entries,
+		})
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+		if len(res.Failed) > 0 {
+			return fmt.Errorf(errorMessage, fmt.Sprintf("%d message(s) failed: %s", len(res.Failed), aws.StringValue(res.Failed[0].Message)))
+		}
+	}
+	return nil
+}
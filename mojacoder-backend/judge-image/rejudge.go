@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+var SUBMISSION_TABLE_NAME = os.Getenv("SUBMISSION_TABLE_NAME")
+
+const SUBMISSION_PROBLEM_INDEX = "problemID-index"
+
+var database *dynamodb.DynamoDB
+
+type SubmissionRecord struct {
+	ID          string                `json:"id"`
+	ProblemID   string                `json:"problemID"`
+	UserID      string                `json:"userID"`
+	Lang        string                `json:"lang"`
+	Status      string                `json:"status"`
+	Verdict     string                `json:"verdict"`
+	PassedCount *int                  `json:"passedCount"`
+	Testcases   []TestcaseResultInput `json:"testcases"`
+}
+
+// RejudgeRecord は再ジャッジする前の判定結果を監査用に残したもの
+type RejudgeRecord struct {
+	Datetime    string `json:"datetime"`
+	Status      string `json:"status"`
+	Verdict     string `json:"verdict,omitempty"`
+	PassedCount *int   `json:"passedCount,omitempty"`
+}
+
+func getSubmissionRecord(ctx context.Context, id string) (SubmissionRecord, error) {
+	const errorMessage = "failed to get submission %s: %v"
+	var record SubmissionRecord
+	res, err := database.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(SUBMISSION_TABLE_NAME),
+		Key:            map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return record, fmt.Errorf(errorMessage, id, err)
+	}
+	if res.Item == nil {
+		return record, fmt.Errorf(errorMessage, id, "not found")
+	}
+	if err = dynamodbattribute.UnmarshalMap(res.Item, &record); err != nil {
+		return record, fmt.Errorf(errorMessage, id, err)
+	}
+	return record, nil
+}
+
+func newRejudgeRecord(submission SubmissionRecord, now time.Time) RejudgeRecord {
+	return RejudgeRecord{
+		Datetime:    now.UTC().Format(time.RFC3339),
+		Status:      submission.Status,
+		Verdict:     submission.Verdict,
+		PassedCount: submission.PassedCount,
+	}
+}
+
+// startRejudgeInput は提出を WJ に戻す更新を作る。record が nil でなければそれまでの判定結果を rejudges に追記する。
+// 失敗したジョブのメッセージは再配信されるので、追記したメッセージの ID を rejudgeMessageID に残し、同じメッセージでは追記しない
+func startRejudgeInput(submissionID, messageID string, record *dynamodb.AttributeValue) *dynamodb.UpdateItemInput {
+	names := map[string]*string{
+		"#status":      aws.String("status"),
+		"#verdict":     aws.String("verdict"),
+		"#maxTime":     aws.String("maxTime"),
+		"#maxMemory":   aws.String("maxMemory"),
+		"#passedCount": aws.String("passedCount"),
+		"#judgeLog":    aws.String("judgeLog"),
+		"#diagnostics": aws.String("diagnostics"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":status": {S: aws.String("WJ")},
+	}
+	update := "SET #status = :status"
+	condition := "attribute_exists(id)"
+	if record != nil {
+		names["#rejudges"] = aws.String("rejudges")
+		values[":empty"] = &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+		values[":record"] = record
+		update += ", #rejudges = list_append(if_not_exists(#rejudges, :empty), :record)"
+		if messageID != "" {
+			names["#rejudgeMessageID"] = aws.String("rejudgeMessageID")
+			values[":messageID"] = &dynamodb.AttributeValue{S: aws.String(messageID)}
+			update += ", #rejudgeMessageID = :messageID"
+			condition += " AND (attribute_not_exists(#rejudgeMessageID) OR #rejudgeMessageID <> :messageID)"
+		}
+	}
+	return &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(SUBMISSION_TABLE_NAME),
+		Key:                       map[string]*dynamodb.AttributeValue{"id": {S: aws.String(submissionID)}},
+		UpdateExpression:          aws.String(update + " REMOVE #verdict, #maxTime, #maxMemory, #passedCount, #judgeLog, #diagnostics"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ConditionExpression:       aws.String(condition),
+	}
+}
+
+// startRejudge はそれまでの判定結果を rejudges に追記し、提出を WJ に戻す。
+// 一部のテストケースだけを再ジャッジするときのために testcases は残す
+func startRejudge(ctx context.Context, submission SubmissionRecord, messageID string) error {
+	const errorMessage = "failed to start rejudging %s: %v"
+	record, err := dynamodbattribute.Marshal([]RejudgeRecord{newRejudgeRecord(submission, time.Now())})
+	if err != nil {
+		return fmt.Errorf(errorMessage, submission.ID, err)
+	}
+	_, err = database.UpdateItemWithContext(ctx, startRejudgeInput(submission.ID, messageID, record))
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		// 再配信されたメッセージなので、前の判定結果はすでに追記してある
+		log.Printf("Rejudge of %s was already recorded for message %s", submission.ID, messageID)
+		_, err = database.UpdateItemWithContext(ctx, startRejudgeInput(submission.ID, messageID, nil))
+	}
+	if err != nil {
+		return fmt.Errorf(errorMessage, submission.ID, err)
+	}
+	return nil
+}
+
+// cancelSubmissionJudging は提出の judgeGeneration を増やし、判定中のジョブがあれば取り消させる
+func cancelSubmissionJudging(ctx context.Context, id string) error {
+	const errorMessage = "failed to cancel judging %s: %v"
+	_, err := database.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(SUBMISSION_TABLE_NAME),
+		Key:                       map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+		UpdateExpression:          aws.String("ADD judgeGeneration :one"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":one": {N: aws.String("1")}},
+		ConditionExpression:       aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		return fmt.Errorf(errorMessage, id, err)
+	}
+	return nil
+}
+
+// prepareRejudge は再ジャッジの前の判定結果を記録し、以降は通常の提出と同じように判定できるジョブを返す
+func prepareRejudge(ctx context.Context, data JudgeQueueData) (JudgeQueueData, error) {
+	submission, err := getSubmissionRecord(ctx, data.SubmissionID)
+	if err != nil {
+		return data, err
+	}
+	log.Printf("Rejudging %s (previous: %s %s)", submission.ID, submission.Status, submission.Verdict)
+	if err = startRejudge(ctx, submission, data.MessageID); err != nil {
+		return data, err
+	}
+	data.Type = "SUBMISSION"
+	data.ProblemID = submission.ProblemID
+	data.UserID = submission.UserID
+	data.Lang = submission.Lang
+	return data, nil
+}
+
+// rejudgeProblem は問題へのすべての提出について再ジャッジのジョブを積む
+func rejudgeProblem(ctx context.Context, data JudgeQueueData) error {
+	const errorMessage = "failed to rejudge problem %s: %v"
+	jobs := []JudgeQueueData{}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(SUBMISSION_TABLE_NAME),
+		IndexName:                 aws.String(SUBMISSION_PROBLEM_INDEX),
+		KeyConditionExpression:    aws.String("problemID = :problemID"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":problemID": {S: aws.String(data.ProblemID)}},
+		ProjectionExpression:      aws.String("id"),
+	}
+	err := database.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			if id := item["id"]; id != nil && id.S != nil {
+				jobs = append(jobs, JudgeQueueData{Type: "REJUDGE", SubmissionID: *id.S, ProblemID: data.ProblemID, Testcases: data.Testcases})
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf(errorMessage, data.ProblemID, err)
+	}
+	for _, job := range jobs {
+		if err = cancelSubmissionJudging(ctx, job.SubmissionID); err != nil {
+			return fmt.Errorf(errorMessage, data.ProblemID, err)
+		}
+	}
+	log.Printf("Queueing %d rejudge(s) for problem %s", len(jobs), data.ProblemID)
+	if err = sendJudgeQueueMessages(ctx, jobs); err != nil {
+		return fmt.Errorf(errorMessage, data.ProblemID, err)
+	}
+	return nil
+}
+
+// previousTestcaseResults は一部のテストケースだけを再ジャッジするとき、残りのテストケースの前回の結果を返す
+func previousTestcaseResults(ctx context.Context, data JudgeQueueData) (map[string]TestcaseResultInput, error) {
+	if len(data.Testcases) == 0 {
+		return nil, nil
+	}
+	submission, err := getSubmissionRecord(ctx, data.SubmissionID)
+	if err != nil {
+		return nil, err
+	}
+	return reusableTestcaseResults(submission.Testcases, data.Testcases), nil
+}
+
+// reusableTestcaseResults は再ジャッジしないテストケースのうち、判定済みのものを返す。
+// WJ と SKIPPED は前回の判定方針や途中で止まった判定によるものなので、もう一度判定する
+func reusableTestcaseResults(testcases []TestcaseResultInput, rejudged []string) map[string]TestcaseResultInput {
+	targets := map[string]bool{}
+	for _, name := range rejudged {
+		targets[name] = true
+	}
+	results := map[string]TestcaseResultInput{}
+	for _, testcase := range testcases {
+		if !targets[testcase.Name] && testcase.Status != "WJ" && testcase.Status != "SKIPPED" {
+			results[testcase.Name] = testcase
+		}
+	}
+	return results
+}
+
+// reusedJudgement は前回の結果を判定方針に記録できる形にする。実行結果は残っていないので、止めるかどうかは状態から決める
+func reusedJudgement(testcase TestcaseResultInput) TestcaseJudgement {
+	return TestcaseJudgement{testcase: testcase, stop: testcase.Status == "TLE" || testcase.Status == "JTLE"}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+func TestSubmissionRecordUnmarshal(t *testing.T) {
+	item := map[string]*dynamodb.AttributeValue{
+		"id":          {S: aws.String("s1")},
+		"problemID":   {S: aws.String("p1")},
+		"status":      {S: aws.String("JUDGED")},
+		"verdict":     {S: aws.String("WA")},
+		"passedCount": {N: aws.String("1")},
+		"testcases": {L: []*dynamodb.AttributeValue{
+			{M: map[string]*dynamodb.AttributeValue{
+				"name":   {S: aws.String("1.txt")},
+				"status": {S: aws.String("AC")},
+				"time":   {N: aws.String("10")},
+				"memory": {N: aws.String("2048")},
+			}},
+		}},
+	}
+	var record SubmissionRecord
+	if err := dynamodbattribute.UnmarshalMap(item, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "s1" || record.ProblemID != "p1" || record.Verdict != "WA" || record.PassedCount == nil || *record.PassedCount != 1 {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+	if len(record.Testcases) != 1 || record.Testcases[0].Name != "1.txt" || record.Testcases[0].Memory != 2048 {
+		t.Fatalf("unexpected testcases: %+v", record.Testcases)
+	}
+}
+
+func TestNewRejudgeRecord(t *testing.T) {
+	passed := 3
+	now := time.Date(2021, 1, 2, 12, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+	record := newRejudgeRecord(SubmissionRecord{Status: "JUDGED", Verdict: "WA", PassedCount: &passed}, now)
+	if record.Datetime != "2021-01-02T03:00:00Z" || record.Status != "JUDGED" || record.Verdict != "WA" || *record.PassedCount != 3 {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+	value, err := dynamodbattribute.Marshal(newRejudgeRecord(SubmissionRecord{Status: "CE"}, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := value.M["verdict"]; ok {
+		t.Errorf("empty verdict must be omitted: %v", value)
+	}
+}
+
+func TestJobID(t *testing.T) {
+	tests := []struct {
+		data JudgeQueueData
+		want string
+	}{
+		{JudgeQueueData{Type: "SUBMISSION", SubmissionID: "s1"}, "s1"},
+		{JudgeQueueData{Type: "REJUDGE", SubmissionID: "s1", ProblemID: "p1"}, "s1"},
+		{JudgeQueueData{Type: "REJUDGE", ProblemID: "p1"}, "p1"},
+		{JudgeQueueData{Type: "PLAYGROUND", SessionID: "x"}, "x"},
+	}
+	for _, test := range tests {
+		if got := jobID(test.data); got != test.want {
+			t.Errorf("jobID(%+v) = %s, want %s", test.data, got, test.want)
+		}
+	}
+}
+
+func TestReusableTestcaseResults(t *testing.T) {
+	testcases := []TestcaseResultInput{
+		{Name: "a", Status: "AC"},
+		{Name: "b", Status: "TLE"},
+		{Name: "c", Status: "SKIPPED"},
+		{Name: "d", Status: "WJ"},
+		{Name: "e", Status: "WA"},
+	}
+	results := reusableTestcaseResults(testcases, []string{"e"})
+	if len(results) != 2 || results["a"].Status != "AC" || results["b"].Status != "TLE" {
+		t.Errorf("reusableTestcaseResults = %v", results)
+	}
+
+	// 前回の TLE を記録すると、STOP_ON_TLE では残りのテストケースが飛ばされる
+	skipper := newTestcaseSkipper(ProblemSetting{judgePolicy: JUDGE_POLICY_STOP_ON_TLE})
+	skipper.record(reusedJudgement(results["a"]))
+	if skipper.skip("c") {
+		t.Error("an accepted result must not stop judging")
+	}
+	skipper.record(reusedJudgement(results["b"]))
+	if !skipper.skip("c") {
+		t.Error("a reused TLE must stop judging under STOP_ON_TLE")
+	}
+}
+
+func TestStartRejudgeInputRecordsMessageOnce(t *testing.T) {
+	record, err := dynamodbattribute.Marshal([]RejudgeRecord{{Status: "JUDGED", Verdict: "WA"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := startRejudgeInput("s1", "m1", record)
+	if !strings.Contains(aws.StringValue(input.UpdateExpression), "list_append") || aws.StringValue(input.ExpressionAttributeValues[":messageID"].S) != "m1" {
+		t.Errorf("the first delivery must append the record: %v", input)
+	}
+	if !strings.Contains(aws.StringValue(input.ConditionExpression), "#rejudgeMessageID <> :messageID") {
+		t.Errorf("a redelivered message must not append again: %s", aws.StringValue(input.ConditionExpression))
+	}
+	retry := startRejudgeInput("s1", "m1", nil)
+	if strings.Contains(aws.StringValue(retry.UpdateExpression), "rejudges") || aws.StringValue(retry.ConditionExpression) != "attribute_exists(id)" {
+		t.Errorf("the retry must only reset the status: %v", retry)
+	}
+	for name := range retry.ExpressionAttributeNames {
+		if !strings.Contains(aws.StringValue(retry.UpdateExpression), name) {
+			t.Errorf("unused attribute name %s", name)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"os"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -17,9 +20,12 @@ const (
 	RunResultStatusRunTimeError
 )
 
+const WAIT_STATUS_LIMIT = 64 // bytes
+
 type RunResult struct {
 	status   RunResultStatus
 	exitCode int
+	signal   int // プログラムを終了させたシグナル。シグナルで終了していなければ 0
 	time     int
 	memory   int
 }
@@ -32,37 +38,179 @@ type RunConfig struct {
 	memoryLimit    int
 	dir            string
 	runCommandArgs []string
+	// 以下はインタラクティブジャッジ用
+	extraFiles      []*os.File
+	closeAfterStart []io.Closer
+	uid             int
 }
 
-func run(definition LanguageDefinition, config RunConfig) (RunResult, error) {
+// run は ctx が取り消されるとプログラムを止め、ctx のエラーを返す
+func run(ctx context.Context, definition LanguageDefinition, config RunConfig) (RunResult, error) {
 	var result RunResult
 	var err error
+	if err = ctx.Err(); err != nil {
+		for _, closer := range config.closeAfterStart {
+			closer.Close()
+		}
+		return result, err
+	}
+	// プログラムの本当の待機状態は mojacoder-sandbox --report-status がこのパイプに書き出す
+	statusReader, statusWriter, err := os.Pipe()
+	if err != nil {
+		for _, closer := range config.closeAfterStart {
+			closer.Close()
+		}
+		return result, err
+	}
+	waitStatus := newLimitedWriter(WAIT_STATUS_LIMIT)
+	waitStatusRead := make(chan struct{})
+	go func() {
+		io.Copy(waitStatus, statusReader)
+		statusReader.Close()
+		close(waitStatusRead)
+	}()
 	additional_memory := 5 * 1024
 	args := strings.Join(config.runCommandArgs, " ")
-	command := fmt.Sprintf("ulimit -u 32 -m %d && timeout --preserve-status -sSIGKILL %d %s %s; EXIT_CODE=$?; kill -SIGKILL -1; wait; exit $EXIT_CODE", config.memoryLimit+additional_memory, config.timeLimit, definition.RunCommand, args)
+	timeLimit := definition.timeLimitMillis(config.timeLimit)
+	memoryLimit := config.memoryLimit + definition.AdditionalMemory
+	statusFd := 3 + len(config.extraFiles)
+	command := fmt.Sprintf("ulimit -u 32 -m %d && timeout --preserve-status -sSIGKILL %.3f %s --report-status %d %s %s; EXIT_CODE=$?; kill -SIGKILL -1; wait; exit $EXIT_CODE", memoryLimit+additional_memory, float64(timeLimit)/1000, SANDBOX_BINARY, statusFd, definition.RunCommand, args)
 	cmd := sandboxedCommand("bash", "-c", command)
 	configureSandboxedCommand(cmd, "")
+	cmd.Env = append(cmd.Env, definition.environment()...)
+	uid := CHILD_UID
+	if config.uid != 0 {
+		uid = config.uid
+	}
+	cmd.SysProcAttr.Credential.Uid = uint32(uid)
 	cmd.Dir = config.dir
 	cmd.Stdin = config.stdin
 	cmd.Stdout = config.stdout
 	cmd.Stderr = config.stderr
+	cmd.ExtraFiles = append(append([]*os.File{}, config.extraFiles...), statusWriter)
 	start := time.Now()
 	err = cmd.Start()
+	statusWriter.Close()
+	for _, closer := range config.closeAfterStart {
+		closer.Close()
+	}
+	if err != nil {
+		return result, err
+	}
+	stopKilling := killOnCancel(ctx, uid)
 	cmd.Wait()
 	end := time.Now()
-	if err != nil {
+	stopKilling()
+	if err = ctx.Err(); err != nil {
 		return result, err
 	}
+	<-waitStatusRead
 	result.exitCode = cmd.ProcessState.ExitCode()
+	result.signal = terminatingSignal(cmd.ProcessState, waitStatus.String())
 	result.time = int((end.Sub(start)).Milliseconds())
 	result.memory = int(cmd.ProcessState.SysUsage().(*syscall.Rusage).Maxrss)
 
-	if result.time > config.timeLimit*1000 {
+	if result.time > timeLimit {
 		result.status = RunResultStatusTimeLimitExceeded
-	} else if result.memory > config.memoryLimit {
+	} else if result.memory > memoryLimit {
 		result.status = RunResultStatusMemoryLimitExceeded
 	} else if !cmd.ProcessState.Success() {
 		result.status = RunResultStatusRunTimeError
 	}
 	return result, nil
 }
+
+// terminatingSignal はプログラムを終了させたシグナルを返す。
+// bash はシグナルを 128+シグナル番号 の終了コードに変えてしまうので、reported (プログラムの待機状態) で区別する。
+// reported はプログラムからも書き込めるので、bash の終了コードと食い違うものは使わない
+func terminatingSignal(state *os.ProcessState, reported string) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return int(status.Signal())
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(reported))
+	if err != nil {
+		return 0
+	}
+	if status := syscall.WaitStatus(value); status.Signaled() && 128+int(status.Signal()) == state.ExitCode() {
+		return int(status.Signal())
+	}
+	return 0
+}
+
+var SIGNAL_NAMES = map[int]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	10: "SIGUSR1",
+	11: "SIGSEGV",
+	12: "SIGUSR2",
+	13: "SIGPIPE",
+	14: "SIGALRM",
+	15: "SIGTERM",
+	24: "SIGXCPU",
+	25: "SIGXFSZ",
+	31: "SIGSYS",
+}
+
+func signalName(signal int) string {
+	if name, ok := SIGNAL_NAMES[signal]; ok {
+		return name
+	}
+	return fmt.Sprintf("SIG%d", signal)
+}
+
+// detail は終了の理由を SIGSEGV や exit 1 のように表す
+func (r RunResult) detail() string {
+	if r.signal != 0 {
+		return signalName(r.signal)
+	}
+	return fmt.Sprintf("exit %d", r.exitCode)
+}
+
+// limitedWriter は上限を超えた書き込みを黙って捨てる
+type limitedWriter struct {
+	builder   strings.Builder
+	limit     int
+	truncated bool
+}
+
+func newLimitedWriter(limit int) *limitedWriter {
+	return &limitedWriter{limit: limit}
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	rest := w.limit - w.builder.Len()
+	if rest <= 0 {
+		w.truncated = w.truncated || len(p) > 0
+		return len(p), nil
+	}
+	if len(p) > rest {
+		w.builder.Write(p[:rest])
+		w.truncated = true
+		return len(p), nil
+	}
+	w.builder.Write(p)
+	return len(p), nil
+}
+
+func (w *limitedWriter) String() string {
+	return w.stringWithin(w.limit)
+}
+
+// stringWithin は書き込まれた内容を limit バイトに収めて返す。切り詰めの印は 1 度だけ付ける
+func (w *limitedWriter) stringWithin(limit int) string {
+	content := w.builder.String()
+	if len(content) > limit {
+		return content[:limit] + "\n(truncated)"
+	}
+	if w.truncated {
+		return content + "\n(truncated)"
+	}
+	return content
+}
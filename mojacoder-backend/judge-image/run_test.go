@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+)
+
+func TestLimitedWriterTruncates(t *testing.T) {
+	writer := newLimitedWriter(5)
+	fmt.Fprint(writer, "abc")
+	fmt.Fprint(writer, "defg")
+	if got := writer.String(); got != "abcde\n(truncated)" {
+		t.Errorf("limitedWriter = %q", got)
+	}
+}
+
+func TestLimitedWriterKeepsShortOutput(t *testing.T) {
+	writer := newLimitedWriter(5)
+	fmt.Fprint(writer, "abcde")
+	if got := writer.String(); got != "abcde" {
+		t.Errorf("limitedWriter = %q", got)
+	}
+}
+
+func TestLimitedWriterTruncatesOnce(t *testing.T) {
+	writer := newLimitedWriter(5)
+	fmt.Fprint(writer, "abcdefg")
+	if got := writer.stringWithin(3); got != "abc\n(truncated)" {
+		t.Errorf("stringWithin(3) = %q", got)
+	}
+	if got := writer.stringWithin(10); got != "abcde\n(truncated)" {
+		t.Errorf("stringWithin(10) = %q", got)
+	}
+}
+
+func TestTerminatingSignal(t *testing.T) {
+	tests := []struct {
+		script   string
+		reported string
+		expected int
+	}{
+		{"exit 0", "0\n", 0},
+		{"exit 1", "256\n", 0},
+		{"exit 139", "35584\n", 0},
+		{"exit 139", "11\n", 11},
+		{"exit 139", "", 0},
+		{"exit 139", "6\n", 0},
+		{"exit 139", "11\n35584\n", 0},
+		{"kill -KILL $$", "", 9},
+	}
+	for _, test := range tests {
+		cmd := exec.Command("sh", "-c", test.script)
+		cmd.Run()
+		if signal := terminatingSignal(cmd.ProcessState, test.reported); signal != test.expected {
+			t.Errorf("%s (%q): terminatingSignal() = %d; expected %d", test.script, test.reported, signal, test.expected)
+		}
+	}
+}
+
+func TestRunResultDetail(t *testing.T) {
+	if detail := (RunResult{exitCode: 139, signal: 11}).detail(); detail != "SIGSEGV" {
+		t.Errorf("detail = %s; expected SIGSEGV", detail)
+	}
+	if detail := (RunResult{exitCode: 1}).detail(); detail != "exit 1" {
+		t.Errorf("detail = %s; expected exit 1", detail)
+	}
+	if detail := (RunResult{signal: 40}).detail(); detail != "SIG40" {
+		t.Errorf("detail = %s; expected SIG40", detail)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+// sampleTestcases は problem.json の samples に挙げられたテストケースを、その順に返す
+func sampleTestcases(samples []string, names []string) []string {
+	exist := map[string]bool{}
+	for _, name := range names {
+		exist[name] = true
+	}
+	result := []string{}
+	for _, sample := range samples {
+		if exist[sample] {
+			result = append(result, sample)
+		}
+	}
+	return result
+}
+
+// sampleTest は提出を作らずに、問題のサンプルだけを本番と同じチェッカーで判定して結果を返す
+func sampleTest(ctx context.Context, definition LanguageDefinition, data JudgeQueueData, problem ProblemSetting, compileOutput string) error {
+	const errorMessage = "failed to test samples: %v"
+	testcasesPath, names, err := downloadTestcases(ctx, data.ProblemID)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	samples := sampleTestcases(problem.samples, names)
+	if len(samples) == 0 {
+		return responsePlayground(ctx, ResponsePlaygroundInput{SessionID: data.SessionID, UserID: data.UserID, Status: "OK", Stderr: "This problem has no sample testcases.", CompileOutput: compileOutput})
+	}
+	for i, name := range samples {
+		var stdout strings.Builder
+		stderr := newLimitedWriter(PLAYGROUND_OUTPUT_LIMIT)
+		// チェッカーのログは問題の作者向けなので返さない
+		judgement, err := judgeTestcase(ctx, definition, problem, testcasesPath, name, &stdout, stderr, ioutil.Discard)
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+		log.Printf("%s: %s", name, judgement.testcase.Status)
+		output := newLimitedWriter(PLAYGROUND_OUTPUT_LIMIT)
+		output.Write([]byte(stdout.String()))
+		response := newPlaygroundRunResponse(data, i, judgement.result, output, stderr, compileOutput)
+		response.Testcase = &name
+		response.Verdict = &judgement.testcase.Status
+		err = responsePlayground(ctx, response)
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+	}
+	return nil
+}
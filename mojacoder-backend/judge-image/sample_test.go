@@ -0,0 +1,17 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSampleTestcases(t *testing.T) {
+	names := []string{"00_sample_01.txt", "01_random_01.txt", "max.txt", "sample-1.txt"}
+	got := sampleTestcases([]string{"max.txt", "sample-1.txt", "missing.txt"}, names)
+	if expected := []string{"max.txt", "sample-1.txt"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("sampleTestcases = %v; expected %v", got, expected)
+	}
+	if got := sampleTestcases(nil, names); len(got) != 0 {
+		t.Errorf("testcases must not be guessed as samples from their names: %v", got)
+	}
+}
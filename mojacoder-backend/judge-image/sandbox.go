@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -39,6 +41,35 @@ func configureSandboxedCommand(cmd *exec.Cmd, homeDir string) {
 	}
 }
 
+// killSandboxedProcesses は uid で動いているプロセスをすべて止める。
+// timeout が別のプロセスグループを作るので、プロセスグループではなく uid ごとまとめて止める
+func killSandboxedProcesses(uid int) error {
+	cmd := exec.Command("bash", "-c", "kill -SIGKILL -1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(uid), Gid: CHILD_GID},
+	}
+	// 止めるプロセスが残っていなければ kill は No such process で失敗するが、それは問題ない
+	if output, err := cmd.CombinedOutput(); err != nil && !strings.Contains(string(output), "No such process") {
+		return fmt.Errorf("failed to kill processes of uid %d: %v: %s", uid, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// killOnCancel は ctx が取り消されたら uid のプロセスを止める。返された関数を呼ぶと見張りをやめる
+func killOnCancel(ctx context.Context, uid int) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := killSandboxedProcesses(uid); err != nil {
+				log.Println(err)
+			}
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
 func createSandboxDirectory(path string) error {
 	if err := os.MkdirAll(path, 0770); err != nil {
 		return err
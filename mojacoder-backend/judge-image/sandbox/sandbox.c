@@ -1,10 +1,12 @@
 #include <errno.h>
+#include <fcntl.h>
 #include <seccomp.h>
 #include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
 #include <sys/prctl.h>
 #include <sys/socket.h>
+#include <sys/wait.h>
 #include <unistd.h>
 
 #define SANDBOX_SETUP_FAILURE 125
@@ -130,11 +132,56 @@ static int self_test(void) {
     return EXIT_SUCCESS;
 }
 
+/*
+ * Runs the command as a child and writes its raw wait status to status_fd.
+ * The shell wrapper turns a signal into 128 + the signal number, which can't
+ * be told apart from a program exiting with that code.
+ */
+static int report_status(int status_fd, char **argv) {
+    pid_t pid;
+    int status;
+
+    if (fcntl(status_fd, F_SETFD, FD_CLOEXEC) != 0) {
+        perror("fcntl(status_fd)");
+        return SANDBOX_SETUP_FAILURE;
+    }
+
+    pid = fork();
+    if (pid < 0) {
+        perror("fork");
+        return SANDBOX_SETUP_FAILURE;
+    }
+    if (pid == 0) {
+        execvp(argv[0], argv);
+        perror("execvp");
+        _exit(errno == ENOENT ? 127 : 126);
+    }
+
+    while (waitpid(pid, &status, 0) < 0) {
+        if (errno != EINTR) {
+            perror("waitpid");
+            return SANDBOX_SETUP_FAILURE;
+        }
+    }
+    dprintf(status_fd, "%d\n", status);
+    close(status_fd);
+
+    if (WIFSIGNALED(status)) {
+        return 128 + WTERMSIG(status);
+    }
+    return WEXITSTATUS(status);
+}
+
 int main(int argc, char **argv) {
     if (argc == 2 && strcmp(argv[1], "--self-test") == 0) {
         return self_test();
     }
 
+    /* The filter is inherited from the sandbox that started the wrapper. */
+    if (argc >= 4 && strcmp(argv[1], "--report-status") == 0) {
+        return report_status(atoi(argv[2]), &argv[3]);
+    }
+
     if (argc < 2) {
         fprintf(stderr, "usage: %s COMMAND [ARG...]\n", argv[0]);
         return EXIT_FAILURE;
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"syscall"
@@ -78,7 +81,7 @@ func TestRunBlocksNetworkAccess(t *testing.T) {
 	definition := LanguageDefinition{
 		RunCommand: fmt.Sprintf("bash -c 'exec 3<>/dev/tcp/127.0.0.1/%d'", port),
 	}
-	result, err := run(definition, RunConfig{
+	result, err := run(context.Background(), definition, RunConfig{
 		stdout:      &stdout,
 		stderr:      &stderr,
 		timeLimit:   2,
@@ -108,17 +111,17 @@ func TestCompileBlocksNetworkAccess(t *testing.T) {
 	defer listener.Close()
 	port := listener.Addr().(*net.TCPAddr).Port
 
-	compiled, stderr, err := compile(LanguageDefinition{
+	result, err := compile(context.Background(), LanguageDefinition{
 		CompileCommand: fmt.Sprintf("bash -c 'exec 3<>/dev/tcp/127.0.0.1/%d'", port),
 	}, dir)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if compiled {
+	if result.compiled() {
 		t.Fatal("network probe unexpectedly compiled")
 	}
-	if !strings.Contains(stderr, "Operation not permitted") {
-		t.Fatalf("compile-time network probe was not rejected by seccomp: %s", stderr)
+	if !strings.Contains(result.output, "Operation not permitted") {
+		t.Fatalf("compile-time network probe was not rejected by seccomp: %s", result.output)
 	}
 	assertListenerNotReached(t, listener)
 }
@@ -141,14 +144,14 @@ func TestCompileUsesUnprivilegedCredentialFreeProcess(t *testing.T) {
 		})
 	}
 
-	compiled, stderr, err := compile(LanguageDefinition{
+	result, err := compile(context.Background(), LanguageDefinition{
 		CompileCommand: `test "$(id -u)" = "400" && test "$(id -g)" = "400" && test -z "${AWS_ACCESS_KEY_ID:-}" && test -z "${AWS_SECRET_ACCESS_KEY:-}" && test ! -r /proc/1/environ`,
 	}, dir)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !compiled {
-		t.Fatalf("compile sandbox identity check failed: %s", stderr)
+	if !result.compiled() {
+		t.Fatalf("compile sandbox identity check failed: %s", result.output)
 	}
 	assertSandboxDirectorySealed(t, dir)
 }
@@ -156,29 +159,261 @@ func TestCompileUsesUnprivilegedCredentialFreeProcess(t *testing.T) {
 func TestCompileReturnsStandardOutputOnFailure(t *testing.T) {
 	dir := sandboxIntegrationDirectory(t)
 
-	compiled, diagnostics, err := compile(LanguageDefinition{
+	result, err := compile(context.Background(), LanguageDefinition{
 		CompileCommand: `printf 'compiler diagnostic\n'; exit 1`,
 	}, dir)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if compiled {
+	if result.compiled() {
 		t.Fatal("failing compiler command unexpectedly succeeded")
 	}
-	if !strings.Contains(diagnostics, "compiler diagnostic") {
-		t.Fatalf("compiler stdout was not returned: %q", diagnostics)
+	if !strings.Contains(result.output, "compiler diagnostic") {
+		t.Fatalf("compiler stdout was not returned: %q", result.output)
+	}
+}
+
+func TestCompileReportsTimeLimitExceeded(t *testing.T) {
+	dir := sandboxIntegrationDirectory(t)
+
+	result, err := compile(context.Background(), LanguageDefinition{
+		CompileCommand:   "sleep 5",
+		CompileTimeLimit: 1,
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.status != CompileResultStatusLimitExceeded {
+		t.Fatalf("compile status = %v, want limit exceeded: %s", result.status, result.output)
+	}
+	if result.verdict() != "CLE" {
+		t.Fatalf("compile verdict = %s, want CLE", result.verdict())
+	}
+}
+
+func TestCompileReportsMemoryLimitExceeded(t *testing.T) {
+	dir := sandboxIntegrationDirectory(t)
+
+	result, err := compile(context.Background(), LanguageDefinition{
+		CompileCommand:     "x=$(head -c 1000000000 /dev/zero | tr '\\0' a)",
+		CompileMemoryLimit: 64 * 1024,
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.verdict() != "CLE" {
+		t.Fatalf("compile verdict = %s, want CLE: %s", result.verdict(), result.output)
 	}
 }
 
 func TestCompileWithoutCommandSealsDirectory(t *testing.T) {
 	dir := sandboxIntegrationDirectory(t)
 
-	compiled, stderr, err := compile(LanguageDefinition{}, dir)
+	result, err := compile(context.Background(), LanguageDefinition{}, dir)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !compiled {
-		t.Fatalf("language without compile command failed: %s", stderr)
+	if !result.compiled() {
+		t.Fatalf("language without compile command failed: %s", result.output)
 	}
 	assertSandboxDirectorySealed(t, dir)
 }
+
+func TestCompileKeepsBoundedWarnings(t *testing.T) {
+	dir := sandboxIntegrationDirectory(t)
+
+	result, err := compile(context.Background(), LanguageDefinition{
+		CompileCommand:      "head -c 100000 /dev/zero | tr '\\0' w >&2",
+		ShowCompileWarnings: true,
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.compiled() {
+		t.Fatalf("compile status = %v: %s", result.status, result.output)
+	}
+	if !strings.HasPrefix(result.output, "www") || !strings.HasSuffix(result.output, "(truncated)") || len(result.output) > COMPILE_WARNING_LIMIT+len("\n(truncated)") {
+		t.Fatalf("warnings are not bounded: %d bytes", len(result.output))
+	}
+}
+
+func TestRunDistinguishesSignalsFromExitCodes(t *testing.T) {
+	dir := sandboxIntegrationDirectory(t)
+
+	tests := []struct {
+		command  string
+		exitCode int
+		signal   int
+	}{
+		{"bash -c 'kill -SEGV $$'", 128 + 11, 11},
+		{"bash -c 'exit 139'", 139, 0},
+	}
+	for _, test := range tests {
+		result, err := run(context.Background(), LanguageDefinition{RunCommand: test.command}, RunConfig{
+			stdin:       strings.NewReader(""),
+			timeLimit:   2,
+			memoryLimit: 128 * 1024,
+			dir:         dir,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.exitCode != test.exitCode || result.signal != test.signal {
+			t.Errorf("%s: exitCode = %d, signal = %d; expected %d, %d", test.command, result.exitCode, result.signal, test.exitCode, test.signal)
+		}
+	}
+}
+
+func TestRunStopsWhenCanceled(t *testing.T) {
+	dir := sandboxIntegrationDirectory(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := run(ctx, LanguageDefinition{RunCommand: "bash -c 'sleep 10 & sleep 10'"}, RunConfig{
+		stdin:          strings.NewReader(""),
+		timeLimit:      10,
+		memoryLimit:    1024 * 1024,
+		dir:            dir,
+		runCommandArgs: []string{},
+	})
+	if err != context.Canceled {
+		t.Fatalf("run returned %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("canceled run took %v", elapsed)
+	}
+}
+
+func TestCompileJavaPackageSubmission(t *testing.T) {
+	dir := sandboxIntegrationDirectory(t)
+	if _, err := exec.LookPath("javac"); err != nil {
+		t.Skip("javac is not installed")
+	}
+	definitions, err := loadLanguageDefinition(LANGUAGE_DEFINITION_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	definition := definitions["java-21"]
+	archive := createZip(t, map[string]string{
+		"Main.java":     "import pkg.Util;\npublic class Main { public static void main(String[] args) { System.out.println(Util.answer()); } }\n",
+		"pkg/Util.java": "package pkg;\npublic class Util { public static int answer() { return 42; } }\n",
+	})
+	if err := os.WriteFile(filepath.Join(dir, definition.Filename), archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+	extracted, message, err := extractSubmittedArchive(dir, definition.Filename)
+	if err != nil || !extracted {
+		t.Fatalf("extractSubmittedArchive = %v, %q, %v", extracted, message, err)
+	}
+	result, err := compile(context.Background(), definition, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.compiled() {
+		t.Fatalf("java package submission did not compile: %s", result.output)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pkg", "Util.class")); err != nil {
+		t.Fatalf("pkg/Util.class was not written: %v", err)
+	}
+	var stdout, stderr strings.Builder
+	runResult, err := run(context.Background(), definition, RunConfig{
+		stdin:       strings.NewReader(""),
+		stdout:      &stdout,
+		stderr:      &stderr,
+		timeLimit:   JUDGE_TIME_LIMIT,
+		memoryLimit: JUDGE_MEMORY_LIMIT,
+		dir:         dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runResult.status != RunResultStatusSuccess || stdout.String() != "42\n" {
+		t.Fatalf("run status = %v, stdout = %q, stderr = %q", runResult.status, stdout.String(), stderr.String())
+	}
+}
+
+// 1 から 1000 までの数を当てるゲーム。in に答え、out に質問回数の上限を置く
+const GUESSING_GAME_INTERACTOR = `[ "$(id -u)" = 401 ] || exit 2
+read secret < "$1" || exit 2
+read limit < "$2" || exit 2
+for ((i = 0; i < limit; i++)); do
+	read guess || exit 1
+	[[ $guess =~ ^[0-9]+$ ]] || exit 1
+	if ((guess == secret)); then echo "="; exit 0; fi
+	if ((guess < secret)); then echo "<"; else echo ">"; fi
+done
+exit 1
+`
+
+func TestInteractiveJudgeRunsGuessingGame(t *testing.T) {
+	dir := sandboxIntegrationDirectory(t)
+	if err := resetSandboxDirectory(SPECIAL_JUDGE_DIR); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(SPECIAL_JUDGE_DIR) })
+	testcasesDir := t.TempDir()
+	inPath := filepath.Join(testcasesDir, "in")
+	outPath := filepath.Join(testcasesDir, "out")
+	if err := os.WriteFile(inPath, []byte("777\n"), 0744); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(outPath, []byte("10\n"), 0744); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		contestant string
+		interactor string
+		want       string
+	}{
+		{"binary search", `lo=1; hi=1000; while ((lo <= hi)); do mid=$(((lo + hi) / 2)); echo $mid; read r; case $r in "=") exit 0;; "<") lo=$((mid + 1));; *) hi=$((mid - 1));; esac; done`, GUESSING_GAME_INTERACTOR, "AC"},
+		{"always one", `while echo 1; do read r || exit 0; done`, GUESSING_GAME_INTERACTOR, "WA"},
+		{"exits early", `exit 0`, GUESSING_GAME_INTERACTOR, "WA"},
+		{"contestant hangs", `sleep 10`, GUESSING_GAME_INTERACTOR, "TLE"},
+		{"interactor hangs", `exit 0`, `sleep 10`, "JTLE"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := os.WriteFile(filepath.Join(dir, "main.sh"), []byte(test.contestant), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(SPECIAL_JUDGE_DIR, "interactor.sh"), []byte(test.interactor), 0644); err != nil {
+				t.Fatal(err)
+			}
+			inFile, err := os.Open(inPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer inFile.Close()
+			outFile, err := os.Open(outPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer outFile.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			defer cancel()
+			judge := InteractiveJudge{lang: LanguageDefinition{RunCommand: "bash interactor.sh"}, wrongAnswerExitCodes: []int{1}}
+			var stderr, interactorStderr strings.Builder
+			start := time.Now()
+			contestant, interactor, err := judge.runInteractiveJudge(ctx, LanguageDefinition{RunCommand: "bash main.sh"}, RunConfig{
+				stderr:         &stderr,
+				timeLimit:      1,
+				memoryLimit:    128 * 1024,
+				dir:            dir,
+				runCommandArgs: []string{},
+			}, &interactorStderr, inFile, outFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("interactive judge took %v", elapsed)
+			}
+			if got := interactiveJudgeStatus(contestant, interactor, judge.wrongAnswerExitCodes); got != test.want {
+				t.Errorf("status = %s; expected %s (contestant %+v, interactor %+v)\n%s%s", got, test.want, contestant, interactor, stderr.String(), interactorStderr.String())
+			}
+		})
+	}
+}
@@ -1,22 +1,56 @@
 package main
 
 import (
+	"context"
 	"io"
 )
 
-func (s SpecialJudge) runSpecialJudge(lang LanguageDefinition, submissionOut io.Reader, inFilePath, outFilePath string) (RunResult, error) {
+// 特殊ジャッジ (インタラクタも同じ) の終了コードの決まり:
+//   - 0 で終了すれば AC
+//   - シグナルでの終了はジャッジプログラムの異常 (JRE) として扱う
+//   - 不正解の終了コードが指定されていなければ、それ以外の 0 でない終了コードはすべて WA
+//   - 指定されていれば (testlib を使う cpp-testlib など)、それ以外の終了コードも JRE として扱う
+//
+// 既存のジャッジプログラムは 0 以外の終了コードで WA を返しているので、厳密な判定は special-judge-langs.json で言語を選んだときだけにする
+
+const SPECIAL_JUDGE_LOG_LIMIT = 64 * 1024
+
+func (s SpecialJudge) runSpecialJudge(ctx context.Context, lang LanguageDefinition, submissionOut io.Reader, stderr io.Writer, inFilePath, outFilePath string) (RunResult, error) {
 	config := RunConfig{
 		stdin:          submissionOut,
 		stdout:         nil,
-		stderr:         nil,
+		stderr:         stderr,
 		timeLimit:      3,
 		memoryLimit:    1024 * 1024,
 		dir:            SPECIAL_JUDGE_DIR,
 		runCommandArgs: []string{inFilePath, outFilePath},
 	}
-	result, err := run(lang, config)
+	result, err := run(ctx, lang, config)
 	if err != nil {
 		return result, err
 	}
 	return result, nil
 }
+
+func specialJudgeStatus(result RunResult, wrongAnswerExitCodes []int) string {
+	switch result.status {
+	case RunResultStatusSuccess:
+		return "AC"
+	case RunResultStatusTimeLimitExceeded:
+		return "JTLE"
+	case RunResultStatusMemoryLimitExceeded:
+		return "JMLE"
+	}
+	if result.signal != 0 {
+		return "JRE"
+	}
+	if len(wrongAnswerExitCodes) == 0 {
+		return "WA"
+	}
+	for _, code := range wrongAnswerExitCodes {
+		if result.exitCode == code {
+			return "WA"
+		}
+	}
+	return "JRE"
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestSpecialJudgeStatus(t *testing.T) {
+	testlib := []int{1, 2}
+	cases := []struct {
+		result               RunResult
+		wrongAnswerExitCodes []int
+		want                 string
+	}{
+		{RunResult{status: RunResultStatusSuccess}, nil, "AC"},
+		{RunResult{status: RunResultStatusTimeLimitExceeded, signal: 9}, nil, "JTLE"},
+		{RunResult{status: RunResultStatusMemoryLimitExceeded}, nil, "JMLE"},
+		// 指定がなければ 0 以外の終了コードはすべて不正解
+		{RunResult{status: RunResultStatusRunTimeError, exitCode: 1}, nil, "WA"},
+		{RunResult{status: RunResultStatusRunTimeError, exitCode: 3}, nil, "WA"},
+		{RunResult{status: RunResultStatusRunTimeError, exitCode: 139}, nil, "WA"},
+		{RunResult{status: RunResultStatusRunTimeError, exitCode: 139, signal: 11}, nil, "JRE"},
+		{RunResult{status: RunResultStatusRunTimeError, exitCode: 1}, testlib, "WA"},
+		{RunResult{status: RunResultStatusRunTimeError, exitCode: 2}, testlib, "WA"},
+		{RunResult{status: RunResultStatusRunTimeError, exitCode: 3}, testlib, "JRE"},
+		{RunResult{status: RunResultStatusRunTimeError, signal: 11}, testlib, "JRE"},
+	}
+	for _, c := range cases {
+		if got := specialJudgeStatus(c.result, c.wrongAnswerExitCodes); got != c.want {
+			t.Errorf("specialJudgeStatus(%+v, %v) = %s; expected %s", c.result, c.wrongAnswerExitCodes, got, c.want)
+		}
+	}
+}
+
+func TestSpecialJudgeLangWrongAnswerExitCodes(t *testing.T) {
+	spjudgelangs, err := loadSpecialJudgeLangs("./special-judge-langs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codes := spjudgelangs["cpp-testlib"].wrongAnswerExitCodes(); len(codes) != 2 || codes[0] != 1 || codes[1] != 2 {
+		t.Errorf("cpp-testlib checkers must follow testlib: %v", codes)
+	}
+	// 既存のジャッジプログラムの判定を変えないよう、ほかの言語は 0 以外の終了コードをすべて不正解とする
+	for name, lang := range spjudgelangs {
+		if codes := lang.wrongAnswerExitCodes(); name != "cpp-testlib" && len(codes) != 0 {
+			t.Errorf("%s checkers must accept any non-zero exit code as WA: %v", name, codes)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -13,14 +15,14 @@ import (
 var storage *s3.S3
 var storageDownloader *s3manager.Downloader
 
-func downloadFromStorage(path string, bucket, key string) error {
+func downloadFromStorage(ctx context.Context, path string, bucket, key string) error {
 	const errorMessage = "Failed to download %s from %s: %v"
 	var err error
 	file, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf(errorMessage, key, bucket, err)
 	}
-	_, err = storageDownloader.Download(file, &s3.GetObjectInput{
+	_, err = storageDownloader.DownloadWithContext(ctx, file, &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
@@ -30,9 +32,42 @@ func downloadFromStorage(path string, bucket, key string) error {
 	return nil
 }
 
-func deleteFromStorage(bucket, key string) error {
+func uploadToStorage(ctx context.Context, path string, bucket, key string) error {
+	const errorMessage = "Failed to upload %s to %s: %v"
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf(errorMessage, key, bucket, err)
+	}
+	defer file.Close()
+	_, err = storage.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   file,
+	})
+	if err != nil {
+		return fmt.Errorf(errorMessage, key, bucket, err)
+	}
+	return nil
+}
+
+func existsInStorage(ctx context.Context, bucket, key string) (bool, error) {
+	const errorMessage = "Failed to check %s in %s: %v"
+	_, err := storage.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NotFound" {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf(errorMessage, key, bucket, err)
+	}
+	return true, nil
+}
+
+func deleteFromStorage(ctx context.Context, bucket, key string) error {
 	const errorMessage = "Failed to delete %s from %s: %v"
-	_, err := storage.DeleteObject(&s3.DeleteObjectInput{
+	_, err := storage.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
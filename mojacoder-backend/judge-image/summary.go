@@ -0,0 +1,48 @@
+package main
+
+import "fmt"
+
+// VERDICT_PRIORITIES は提出全体の結果として採用する順に並べたテストケースの状態。
+// ジャッジ側の異常を最優先し、次に実行時の異常、最後に WA とする
+var VERDICT_PRIORITIES = []string{"JRE", "JMLE", "JTLE", "RE", "MLE", "TLE", "WA"}
+
+// SubmissionSummary は提出全体の結果で、ジャッジの完了時にだけ送る
+type SubmissionSummary struct {
+	Verdict     string `json:"verdict"`
+	MaxTime     int    `json:"maxTime"`
+	MaxMemory   int    `json:"maxMemory"`
+	PassedCount int    `json:"passedCount"`
+}
+
+func verdictPriority(status string) int {
+	for i, verdict := range VERDICT_PRIORITIES {
+		if verdict == status {
+			return i
+		}
+	}
+	return len(VERDICT_PRIORITIES)
+}
+
+// summarizeTestcases はテストケースごとの結果から提出全体の結果を求める。
+// SKIPPED は他のテストケースの失敗によるものなので全体の結果には影響しない。
+// テストケースが 1 つもなければ何も確かめていないので、AC とはせずにエラーを返す
+func summarizeTestcases(testcases []TestcaseResultInput) (SubmissionSummary, error) {
+	if len(testcases) == 0 {
+		return SubmissionSummary{}, fmt.Errorf("no testcases to judge")
+	}
+	summary := SubmissionSummary{Verdict: "AC"}
+	for _, testcase := range testcases {
+		if testcase.Time > summary.MaxTime {
+			summary.MaxTime = testcase.Time
+		}
+		if testcase.Memory > summary.MaxMemory {
+			summary.MaxMemory = testcase.Memory
+		}
+		if testcase.Status == "AC" {
+			summary.PassedCount++
+		} else if verdictPriority(testcase.Status) < verdictPriority(summary.Verdict) {
+			summary.Verdict = testcase.Status
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeTestcases(t *testing.T) {
+	summary, err := summarizeTestcases([]TestcaseResultInput{
+		{Name: "a", Status: "AC", Time: 10, Memory: 300},
+		{Name: "b", Status: "WA", Time: 30, Memory: 200},
+		{Name: "c", Status: "TLE", Time: 2100, Memory: 100},
+		{Name: "d", Status: "SKIPPED", Time: -1, Memory: -1},
+	})
+	expected := SubmissionSummary{Verdict: "TLE", MaxTime: 2100, MaxMemory: 300, PassedCount: 1}
+	if err != nil || summary != expected {
+		t.Errorf("summarizeTestcases() = %v, %v; expected %v", summary, err, expected)
+	}
+	if summary, err := summarizeTestcases([]TestcaseResultInput{{Name: "a", Status: "AC"}}); err != nil || summary.Verdict != "AC" || summary.PassedCount != 1 {
+		t.Errorf("all AC: %v, %v", summary, err)
+	}
+}
+
+func TestSummarizeNoTestcases(t *testing.T) {
+	if summary, err := summarizeTestcases([]TestcaseResultInput{}); err == nil {
+		t.Errorf("no testcases must not be summarized: %v", summary)
+	}
+}
+
+func TestSubmissionSummaryIsOmittedUntilJudged(t *testing.T) {
+	bytes, err := json.Marshal(UpdateSubmissionStatusInput{ID: "id", Status: "WJ"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bytes), "verdict") {
+		t.Errorf("summary must be omitted: %s", bytes)
+	}
+	bytes, err = json.Marshal(UpdateSubmissionStatusInput{ID: "id", Status: "JUDGED", SubmissionSummary: &SubmissionSummary{Verdict: "AC"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bytes), `"verdict":"AC"`) {
+		t.Errorf("summary must be included: %s", bytes)
+	}
+}
+
+func TestVerdictPrioritiesAreTestcaseStatuses(t *testing.T) {
+	schema, err := ioutil.ReadFile("../graphql/schema.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enum := regexp.MustCompile(`enum TestcaseResultStatus \{([^}]*)\}`).FindSubmatch(schema)
+	if enum == nil {
+		t.Fatal("TestcaseResultStatus not found")
+	}
+	statuses := strings.Fields(string(enum[1]))
+	for _, verdict := range VERDICT_PRIORITIES {
+		found := false
+		for _, status := range statuses {
+			found = found || status == verdict
+		}
+		if !found {
+			t.Errorf("%s is not in TestcaseResultStatus", verdict)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var TESTCASES_FOR_VIEW_BUCKET_NAME = os.Getenv("TESTCASES_FOR_VIEW_BUCKET_NAME")
+
+const GENERATOR_DIR = "/tmp/mojacoder-judge-generator/"
+const GENERATOR_SOLUTION_DIR = "/tmp/mojacoder-judge-generator-solution/"
+const GENERATOR_MANIFEST_PATH = "/tmp/mojacoder-generator.json"
+const GENERATOR_SCRIPT_PATH = "/tmp/mojacoder-generator-script.txt"
+const GENERATED_TESTCASES_PATH = "/tmp/mojacoder-generated-testcases.zip"
+const GENERATOR_TIME_LIMIT = 10                        // 秒
+const GENERATED_TESTCASE_SIZE_LIMIT = 64 * 1024 * 1024 // バイト
+
+// GeneratorManifest は problemID/generator.json に置かれる、テストケース生成に使うプログラムの一覧。
+// Testcases は生成の元にする手作りのテストケースで、空なら判定用の zip を使う
+type GeneratorManifest struct {
+	Lang      string            `json:"lang"`
+	Key       string            `json:"key"`
+	Script    string            `json:"script"`
+	Testcases string            `json:"testcases"`
+	Solution  ReferenceSolution `json:"solution"`
+}
+
+// GeneratorInvocation は生成スクリプトの 1 行で、Args (シードを含む) を渡して生成した入力を Name に書き出す
+type GeneratorInvocation struct {
+	Name string
+	Args []string
+}
+
+// cappedWriter は上限を超えた書き込みを捨て、exceeded を立てる
+type cappedWriter struct {
+	writer   io.Writer
+	rest     int64
+	exceeded bool
+}
+
+func (w *cappedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > w.rest {
+		w.exceeded = true
+		if _, err := w.writer.Write(p[:w.rest]); err != nil {
+			return 0, err
+		}
+		w.rest = 0
+		return len(p), nil
+	}
+	w.rest -= int64(len(p))
+	return w.writer.Write(p)
+}
+
+// parseGeneratorScript は「テストケース名 引数...」の行を読む。空行と # で始まる行は読み飛ばす
+func parseGeneratorScript(script string) ([]GeneratorInvocation, error) {
+	invocations := []GeneratorInvocation{}
+	names := map[string]bool{}
+	for index, line := range strings.Split(script, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		name := fields[0]
+		if name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+			return nil, fmt.Errorf("line %d: invalid testcase name '%s'", index+1, name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("line %d: duplicated testcase '%s'", index+1, name)
+		}
+		names[name] = true
+		invocations = append(invocations, GeneratorInvocation{Name: name, Args: fields[1:]})
+	}
+	return invocations, nil
+}
+
+func downloadGeneratorManifest(ctx context.Context, problemID string) (*GeneratorManifest, error) {
+	key := path.Join(problemID, "generator.json")
+	exist, err := existsInStorage(ctx, JUDGECODES_BUCKET_NAME, key)
+	if err != nil || !exist {
+		return nil, err
+	}
+	if err = downloadFromStorage(ctx, GENERATOR_MANIFEST_PATH, JUDGECODES_BUCKET_NAME, key); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(GENERATOR_MANIFEST_PATH)
+	if err != nil {
+		return nil, err
+	}
+	var manifest *GeneratorManifest
+	err = json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+func downloadGeneratorScript(ctx context.Context, key string) (string, error) {
+	if err := downloadFromStorage(ctx, GENERATOR_SCRIPT_PATH, JUDGECODES_BUCKET_NAME, key); err != nil {
+		return "", err
+	}
+	script, err := ioutil.ReadFile(GENERATOR_SCRIPT_PATH)
+	return string(script), err
+}
+
+// runGeneratorProgram は dir のプログラムを実行し、標準出力を outputPath に書き出す。
+// 正常に終了しなかった場合は作者向けのメッセージを返す
+func runGeneratorProgram(ctx context.Context, lang LanguageDefinition, dir string, args []string, stdin io.Reader, outputPath string) (string, error) {
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return "", err
+	}
+	defer output.Close()
+	stdout := &cappedWriter{writer: output, rest: GENERATED_TESTCASE_SIZE_LIMIT}
+	stderr := newLimitedWriter(VALIDATOR_LOG_LIMIT)
+	result, err := run(ctx, lang, RunConfig{
+		stdin:          stdin,
+		stdout:         stdout,
+		stderr:         stderr,
+		timeLimit:      GENERATOR_TIME_LIMIT,
+		memoryLimit:    JUDGE_MEMORY_LIMIT,
+		dir:            dir,
+		runCommandArgs: args,
+	})
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case result.status == RunResultStatusTimeLimitExceeded:
+		return "time limit exceeded", nil
+	case result.status == RunResultStatusMemoryLimitExceeded:
+		return "memory limit exceeded", nil
+	case stdout.exceeded:
+		return fmt.Sprintf("output exceeds %d bytes", GENERATED_TESTCASE_SIZE_LIMIT), nil
+	case result.status == RunResultStatusRunTimeError:
+		return fmt.Sprintf("%s\n%s", result.detail(), strings.TrimSpace(stderr.String())), nil
+	}
+	return "", nil
+}
+
+// generateTestcase は生成プログラムで入力を、作者の解答でその出力を作る
+func generateTestcase(ctx context.Context, generator, solution LanguageDefinition, testcasesPath string, invocation GeneratorInvocation) (string, error) {
+	inPath := filepath.Join(testcasesPath, "in", invocation.Name)
+	message, err := runGeneratorProgram(ctx, generator, GENERATOR_DIR, quoteArgs(invocation.Args), nil, inPath)
+	if err != nil || message != "" {
+		return fmt.Sprintf("Generator failed on %s: %s", invocation.Name, message), err
+	}
+	inFile, err := os.Open(inPath)
+	if err != nil {
+		return "", err
+	}
+	defer inFile.Close()
+	message, err = runGeneratorProgram(ctx, solution, GENERATOR_SOLUTION_DIR, []string{}, inFile, filepath.Join(testcasesPath, "out", invocation.Name))
+	if err != nil || message != "" {
+		return fmt.Sprintf("Solution failed on %s: %s", invocation.Name, message), err
+	}
+	return "", nil
+}
+
+// quoteArgs は生成スクリプトの引数を bash -c に渡せるように 1 つずつクォートする
+func quoteArgs(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return quoted
+}
+
+// generatedTestcasesKey は生成したテストケースの zip を置くキー。
+// 配置ごとに別のキーにして、古い生成の結果が新しい配置のテストケースを上書きしないようにする
+func generatedTestcasesKey(problemID, version string) string {
+	if version == "" {
+		return path.Join(problemID, "generated.zip")
+	}
+	return path.Join(problemID, "generated-"+version+".zip")
+}
+
+// uploadGeneratedTestcases は手作りのものと合わせたテストケースを zip にまとめて key に置く
+func uploadGeneratedTestcases(ctx context.Context, key, testcasesPath string) error {
+	if err := zipDirectory(testcasesPath, GENERATED_TESTCASES_PATH); err != nil {
+		return err
+	}
+	defer os.Remove(GENERATED_TESTCASES_PATH)
+	return uploadToStorage(ctx, GENERATED_TESTCASES_PATH, TESTCASES_BUCKET_NAME, key)
+}
+
+// uploadGeneratedTestcasesForView は生成したテストケースを閲覧用に置く
+func uploadGeneratedTestcasesForView(ctx context.Context, problemID, testcasesPath string, invocations []GeneratorInvocation) error {
+	for _, invocation := range invocations {
+		for _, dir := range []string{"in", "out"} {
+			err := uploadToStorage(ctx, filepath.Join(testcasesPath, dir, invocation.Name), TESTCASES_FOR_VIEW_BUCKET_NAME, path.Join(problemID, dir, invocation.Name))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// downloadGeneratorTestcases は生成の元にする手作りのテストケースを展開する
+func downloadGeneratorTestcases(ctx context.Context, problemID string, manifest *GeneratorManifest) (string, error) {
+	var testcasesPath string
+	var err error
+	if manifest.Testcases == "" {
+		testcasesPath, _, err = downloadTestcases(ctx, problemID)
+	} else {
+		testcasesPath, _, err = downloadTestcasesFrom(ctx, JUDGECODES_BUCKET_NAME, manifest.Testcases)
+	}
+	return testcasesPath, err
+}
+
+// generateTestcases は生成スクリプトに従ってテストケースを作り、手作りのものと合わせたディレクトリと生成したテストケースを返す。
+// 作者の用意したものに問題があった場合は作者向けのメッセージを返す
+func generateTestcases(ctx context.Context, definitions map[string]LanguageDefinition, spjudgelangs map[string]SpecialJudgeLang, data JudgeQueueData) (string, []GeneratorInvocation, string, error) {
+	manifest, err := downloadGeneratorManifest(ctx, data.ProblemID)
+	if err != nil {
+		return "", nil, "", err
+	}
+	if manifest == nil {
+		return "", nil, "No testcase generator.", nil
+	}
+	script, err := downloadGeneratorScript(ctx, manifest.Script)
+	if err != nil {
+		return "", nil, "", err
+	}
+	invocations, err := parseGeneratorScript(script)
+	if err != nil {
+		return "", nil, fmt.Sprintf("Generator script error: %v", err), nil
+	}
+	generator, err := getJudgeLang(manifest.Lang, spjudgelangs, definitions)
+	if err != nil {
+		return "", nil, fmt.Sprintf("Generator language error: %v", err), nil
+	}
+	solution, exist := definitions[manifest.Solution.Lang]
+	if !exist {
+		return "", nil, fmt.Sprintf("Language not found: %s", manifest.Solution.Lang), nil
+	}
+	prepared, compileOutput, err := prepareProblemProgram(ctx, GENERATOR_DIR, manifest.Key, generator)
+	if err != nil || !prepared {
+		return "", nil, "Generator compile error:\n" + compileOutput, err
+	}
+	prepared, compileOutput, err = prepareProblemProgram(ctx, GENERATOR_SOLUTION_DIR, manifest.Solution.Key, solution)
+	if err != nil || !prepared {
+		return "", nil, "Solution compile error:\n" + compileOutput, err
+	}
+	if err = resetSandboxDirectory(TEMP_DIR); err != nil {
+		return "", nil, "", err
+	}
+	testcasesPath, err := downloadGeneratorTestcases(ctx, data.ProblemID, manifest)
+	if err != nil {
+		return "", nil, "", err
+	}
+	if err = os.MkdirAll(filepath.Join(testcasesPath, "out"), 0775); err != nil {
+		return "", nil, "", err
+	}
+	if err = disallowAccessTestcases(testcasesPath); err != nil {
+		return "", nil, "", err
+	}
+	for _, invocation := range invocations {
+		log.Printf("Generating testcase %s...", invocation.Name)
+		message, err := generateTestcase(ctx, generator, solution, testcasesPath, invocation)
+		if err != nil || message != "" {
+			return "", nil, message, err
+		}
+	}
+	return testcasesPath, invocations, "", nil
+}
+
+// readTestcasesVersion は問題が配置されるたびに変わるテストケースの版を読む。以前に配置された問題では空になる
+func readTestcasesVersion(ctx context.Context, problemID string) (string, error) {
+	res, err := database.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(PROBLEM_TABLE_NAME),
+		Key:                  map[string]*dynamodb.AttributeValue{"id": {S: aws.String(problemID)}},
+		ProjectionExpression: aws.String("testcasesVersion"),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if version := res.Item["testcasesVersion"]; version != nil {
+		return aws.StringValue(version.S), nil
+	}
+	return "", nil
+}
+
+// markTestcasesReady は判定に使うテストケースを key に切り替え、問題を判定できる状態にする。
+// 生成中に問題が配置し直されていたら何もせず false を返す
+func markTestcasesReady(ctx context.Context, problemID, version, key string) (bool, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName:        aws.String(PROBLEM_TABLE_NAME),
+		Key:              map[string]*dynamodb.AttributeValue{"id": {S: aws.String(problemID)}},
+		UpdateExpression: aws.String("SET testcasesStatus = :ready, testcasesKey = :key"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":ready": {S: aws.String("READY")},
+			":key":   {S: aws.String(key)},
+		},
+		ConditionExpression: aws.String("attribute_exists(id) AND attribute_not_exists(testcasesVersion)"),
+	}
+	if version != "" {
+		input.ExpressionAttributeValues[":version"] = &dynamodb.AttributeValue{S: aws.String(version)}
+		input.ConditionExpression = aws.String("testcasesVersion = :version")
+	}
+	_, err := database.UpdateItemWithContext(ctx, input)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// generateProblemTestcases はテストケースを生成し、続けて問題を検証する。生成に失敗したことは検証の結果として記録する。
+// 生成中に問題が配置し直されたときは、その配置で積まれた生成に任せて結果を捨てる
+func generateProblemTestcases(ctx context.Context, definitions map[string]LanguageDefinition, spjudgelangs map[string]SpecialJudgeLang, data JudgeQueueData) error {
+	const errorMessage = "failed to generate testcases: %v"
+	version, err := readTestcasesVersion(ctx, data.ProblemID)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	err = updateProblemValidation(ctx, data.ProblemID, newProblemValidation("VALIDATING", "", nil, nil))
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	testcasesPath, invocations, message, err := generateTestcases(ctx, definitions, spjudgelangs, data)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	if message != "" {
+		err = updateProblemValidation(ctx, data.ProblemID, newProblemValidation("FAILED", message, nil, nil))
+		if err != nil {
+			return fmt.Errorf(errorMessage, err)
+		}
+		return nil
+	}
+	key := generatedTestcasesKey(data.ProblemID, version)
+	if err = uploadGeneratedTestcases(ctx, key, testcasesPath); err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	ready, err := markTestcasesReady(ctx, data.ProblemID, version, key)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	if !ready {
+		log.Printf("Problem %s was redeployed while generating testcases", data.ProblemID)
+		return nil
+	}
+	if err = uploadGeneratedTestcasesForView(ctx, data.ProblemID, testcasesPath, invocations); err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	return validateProblem(ctx, definitions, spjudgelangs, data)
+}
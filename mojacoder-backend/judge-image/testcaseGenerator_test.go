@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGeneratorScript(t *testing.T) {
+	script := "# name args...\n\n01_small.txt 10 --seed 1\n02_large.txt 200000 --seed 2\n03_max.txt\n"
+	invocations, err := parseGeneratorScript(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GeneratorInvocation{
+		{Name: "01_small.txt", Args: []string{"10", "--seed", "1"}},
+		{Name: "02_large.txt", Args: []string{"200000", "--seed", "2"}},
+		{Name: "03_max.txt", Args: []string{}},
+	}
+	if !reflect.DeepEqual(invocations, want) {
+		t.Errorf("unexpected invocations: %+v", invocations)
+	}
+	for _, script := range []string{"../01.txt 1", "01.txt 1\n01.txt 2", ".. 1"} {
+		if _, err := parseGeneratorScript(script); err == nil {
+			t.Errorf("script must be rejected: %q", script)
+		}
+	}
+}
+
+func TestQuoteArgs(t *testing.T) {
+	quoted := quoteArgs([]string{"10", "it's", "$(id)"})
+	if strings.Join(quoted, " ") != `'10' 'it'\''s' '$(id)'` {
+		t.Errorf("unexpected args: %v", quoted)
+	}
+}
+
+func TestCappedWriter(t *testing.T) {
+	var builder strings.Builder
+	writer := &cappedWriter{writer: &builder, rest: 5}
+	writer.Write([]byte("abc"))
+	if writer.exceeded {
+		t.Error("writer must not exceed yet")
+	}
+	n, err := writer.Write([]byte("defg"))
+	if n != 4 || err != nil || !writer.exceeded || builder.String() != "abcde" {
+		t.Errorf("unexpected write: %d %v %v %q", n, err, writer.exceeded, builder.String())
+	}
+}
+
+func TestGeneratedTestcasesKeyIsPerDeploy(t *testing.T) {
+	first := generatedTestcasesKey("p1", "v1")
+	second := generatedTestcasesKey("p1", "v2")
+	if first == second || first == "p1.zip" || generatedTestcasesKey("p1", "") == "p1.zip" {
+		t.Errorf("generated testcases must not overwrite another deploy: %s, %s", first, second)
+	}
+}
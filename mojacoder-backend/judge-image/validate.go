@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const LANGUAGE_TESTS_DIR = "./language-tests"
+const LANGUAGE_VALIDATION_DIR = "/tmp/mojacoder-judge-validation/"
+const LANGUAGE_VALIDATION_OUTPUT = "Hello, World!"
+
+// VALIDATE_LANGUAGES_ON_STARTUP が "warn" なら起動時に検証して結果を記録し、"fatal" なら失敗時に終了する
+var VALIDATE_LANGUAGES_ON_STARTUP = os.Getenv("VALIDATE_LANGUAGES_ON_STARTUP")
+
+// validateLanguage は同梱の Hello World を実際にコンパイル・実行し、処理系のバージョンを確かめる
+func validateLanguage(ctx context.Context, id string, definition LanguageDefinition) error {
+	if err := resetSandboxDirectory(LANGUAGE_VALIDATION_DIR); err != nil {
+		return err
+	}
+	defer os.RemoveAll(LANGUAGE_VALIDATION_DIR)
+
+	if definition.VersionCommand != "" {
+		// バージョンの確認も compile と同じ制限・サンドボックスの下で行う
+		result, err := compile(ctx, LanguageDefinition{CompileCommand: definition.VersionCommand, Environment: definition.Environment, ShowCompileWarnings: true}, LANGUAGE_VALIDATION_DIR)
+		if err != nil {
+			return err
+		}
+		if !result.compiled() {
+			return fmt.Errorf("version command failed: %s", result.output)
+		}
+		if !strings.Contains(result.output, definition.Version) {
+			return fmt.Errorf("version %s not found in: %s", definition.Version, strings.TrimSpace(result.output))
+		}
+		if err := resetSandboxDirectory(LANGUAGE_VALIDATION_DIR); err != nil {
+			return err
+		}
+	}
+
+	source, err := ioutil.ReadFile(filepath.Join(LANGUAGE_TESTS_DIR, id, definition.Filename))
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(LANGUAGE_VALIDATION_DIR, definition.Filename), source, 0644); err != nil {
+		return err
+	}
+	compileResult, err := compile(ctx, definition, LANGUAGE_VALIDATION_DIR)
+	if err != nil {
+		return err
+	}
+	if !compileResult.compiled() {
+		return fmt.Errorf("compile failed (%s): %s", compileResult.verdict(), compileResult.output)
+	}
+	var stdout, stderr strings.Builder
+	result, err := run(ctx, definition, RunConfig{
+		stdin:          strings.NewReader(""),
+		stdout:         &stdout,
+		stderr:         &stderr,
+		timeLimit:      PLAYGROUND_TIME_LIMIT * 5,
+		memoryLimit:    1024 * 1024,
+		dir:            LANGUAGE_VALIDATION_DIR,
+		runCommandArgs: []string{},
+	})
+	if err != nil {
+		return err
+	}
+	if result.status != RunResultStatusSuccess {
+		return fmt.Errorf("run failed (exit code %d): %s", result.exitCode, stderr.String())
+	}
+	if output := strings.TrimSpace(stdout.String()); output != LANGUAGE_VALIDATION_OUTPUT {
+		return fmt.Errorf("unexpected output: %q", output)
+	}
+	return nil
+}
+
+// validateLanguages はすべての言語を検証し、失敗した言語ごとのエラーを返す
+func validateLanguages(ctx context.Context, definitions map[string]LanguageDefinition, spjudgelangs map[string]SpecialJudgeLang) []error {
+	var errs []error
+	for name, spjudgelang := range spjudgelangs {
+		if _, exist := definitions[spjudgelang.Id]; !exist {
+			errs = append(errs, fmt.Errorf("special judge lang %s: language %s not found", name, spjudgelang.Id))
+		}
+	}
+	ids := make([]string, 0, len(definitions))
+	for id := range definitions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		log.Printf("Validating %s...", id)
+		if err := validateLanguage(ctx, id, definitions[id]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", id, err))
+		}
+	}
+	return errs
+}
+
+func runLanguageValidation(ctx context.Context, definitions map[string]LanguageDefinition, spjudgelangs map[string]SpecialJudgeLang) bool {
+	errs := validateLanguages(ctx, definitions, spjudgelangs)
+	for _, err := range errs {
+		log.Println(err)
+	}
+	if len(errs) > 0 {
+		log.Printf("%d language validation(s) failed.", len(errs))
+		return false
+	}
+	log.Println("All languages are valid.")
+	return true
+}
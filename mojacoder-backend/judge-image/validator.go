@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const VALIDATOR_DIR = "/tmp/mojacoder-judge-validator/"
+const VALIDATOR_TIME_LIMIT = 10 // 秒
+const VALIDATOR_LOG_LIMIT = 4 * 1024
+const MAX_REPORTED_INVALID_TESTCASES = 5
+
+// TestcaseValidation は入力検証プログラムでテストケースの入力を確かめた結果
+type TestcaseValidation struct {
+	Name    string  `json:"name"`
+	Valid   bool    `json:"valid"`
+	Message *string `json:"message"`
+}
+
+// prepareValidator は入力検証プログラムを特殊ジャッジと同じように専用のディレクトリでコンパイルする。
+// コンパイルに失敗した場合は false とコンパイラの出力を返す。
+func prepareValidator(ctx context.Context, problemID string, lang LanguageDefinition) (bool, string, error) {
+	return prepareProblemProgram(ctx, VALIDATOR_DIR, path.Join(problemID, "validator"), lang)
+}
+
+// prepareProblemProgram は問題の作者が用意したプログラムを dir に取ってきてコンパイルする
+func prepareProblemProgram(ctx context.Context, dir, key string, lang LanguageDefinition) (bool, string, error) {
+	if err := resetSandboxDirectory(dir); err != nil {
+		return false, "", err
+	}
+	err := downloadFromStorage(ctx, filepath.Join(dir, lang.Filename), JUDGECODES_BUCKET_NAME, key)
+	if err != nil {
+		return false, "", err
+	}
+	compileResult, err := compile(ctx, lang, dir)
+	if err != nil {
+		return false, "", err
+	}
+	if !compileResult.compiled() {
+		return false, compileResult.output, nil
+	}
+	return true, "", nil
+}
+
+// newTestcaseValidation は入力検証プログラムが正常に終了すれば入力が制約を満たしているとみなす。
+// testlib の validator に合わせ、違反の内容は標準エラー出力から取る
+func newTestcaseValidation(name string, result RunResult, stderr string) TestcaseValidation {
+	validation := TestcaseValidation{Name: name, Valid: result.status == RunResultStatusSuccess}
+	message := strings.TrimSpace(stderr)
+	switch result.status {
+	case RunResultStatusTimeLimitExceeded:
+		message = "Validator time limit exceeded."
+	case RunResultStatusMemoryLimitExceeded:
+		message = "Validator memory limit exceeded."
+	case RunResultStatusRunTimeError:
+		if message == "" && result.signal != 0 {
+			message = fmt.Sprintf("Validator was killed by signal %d.", result.signal)
+		} else if message == "" {
+			message = fmt.Sprintf("Validator exited with code %d.", result.exitCode)
+		}
+	}
+	if message != "" {
+		validation.Message = &message
+	}
+	return validation
+}
+
+// validateTestcases は問題に入力検証プログラムがあれば、すべての入力ファイルをそれで確かめる。
+// 検証プログラムを用意できなかった場合は作者向けのメッセージを返す
+func validateTestcases(ctx context.Context, definitions map[string]LanguageDefinition, spjudgelangs map[string]SpecialJudgeLang, data JudgeQueueData, problem ProblemSetting) ([]TestcaseValidation, string, error) {
+	testcases := []TestcaseValidation{}
+	if problem.validatorLang == "" {
+		return testcases, "", nil
+	}
+	lang, err := getJudgeLang(problem.validatorLang, spjudgelangs, definitions)
+	if err != nil {
+		return testcases, fmt.Sprintf("Validator language error: %v", err), nil
+	}
+	prepared, compileOutput, err := prepareValidator(ctx, data.ProblemID, lang)
+	if err != nil {
+		return testcases, "", err
+	}
+	if !prepared {
+		return testcases, "Validator compile error:\n" + compileOutput, nil
+	}
+	if err = resetSandboxDirectory(TEMP_DIR); err != nil {
+		return testcases, "", err
+	}
+	testcasesPath, names, err := downloadTestcases(ctx, data.ProblemID)
+	if err != nil {
+		return testcases, "", err
+	}
+	for _, name := range names {
+		inFile, err := os.Open(filepath.Join(testcasesPath, "in", name))
+		if err != nil {
+			return testcases, "", err
+		}
+		stderr := newLimitedWriter(VALIDATOR_LOG_LIMIT)
+		result, err := run(ctx, lang, RunConfig{
+			stdin:          inFile,
+			stderr:         stderr,
+			timeLimit:      VALIDATOR_TIME_LIMIT,
+			memoryLimit:    JUDGE_MEMORY_LIMIT,
+			dir:            VALIDATOR_DIR,
+			runCommandArgs: []string{},
+		})
+		inFile.Close()
+		if err != nil {
+			return testcases, "", err
+		}
+		testcases = append(testcases, newTestcaseValidation(name, result, stderr.String()))
+	}
+	return testcases, invalidTestcasesMessage(testcases), nil
+}
+
+// invalidTestcasesMessage は制約を満たさないテストケースがあれば、その数と先頭のいくつかの名前を返す
+func invalidTestcasesMessage(testcases []TestcaseValidation) string {
+	invalid := []string{}
+	for _, testcase := range testcases {
+		if !testcase.Valid {
+			invalid = append(invalid, testcase.Name)
+		}
+	}
+	if len(invalid) == 0 {
+		return ""
+	}
+	names := invalid
+	if len(names) > MAX_REPORTED_INVALID_TESTCASES {
+		names = append(names[:MAX_REPORTED_INVALID_TESTCASES:MAX_REPORTED_INVALID_TESTCASES], "...")
+	}
+	return fmt.Sprintf("%d testcase(s) violate the input constraints: %s", len(invalid), strings.Join(names, ", "))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewTestcaseValidation(t *testing.T) {
+	valid := newTestcaseValidation("01.txt", RunResult{status: RunResultStatusSuccess}, "")
+	if !valid.Valid || valid.Message != nil {
+		t.Errorf("unexpected validation: %+v", valid)
+	}
+	invalid := newTestcaseValidation("02.txt", RunResult{status: RunResultStatusRunTimeError, exitCode: 3}, "FAIL Integer 0 violates the range [1, 100]\n")
+	if invalid.Valid || invalid.Message == nil || *invalid.Message != "FAIL Integer 0 violates the range [1, 100]" {
+		t.Errorf("unexpected validation: %+v", invalid)
+	}
+	silent := newTestcaseValidation("03.txt", RunResult{status: RunResultStatusRunTimeError, exitCode: 3}, "")
+	if silent.Valid || silent.Message == nil || !strings.Contains(*silent.Message, "code 3") {
+		t.Errorf("unexpected validation: %+v", silent)
+	}
+	timeout := newTestcaseValidation("04.txt", RunResult{status: RunResultStatusTimeLimitExceeded}, "partial")
+	if timeout.Valid || timeout.Message == nil || *timeout.Message != "Validator time limit exceeded." {
+		t.Errorf("unexpected validation: %+v", timeout)
+	}
+}
+
+func TestInvalidTestcasesMessage(t *testing.T) {
+	testcases := []TestcaseValidation{}
+	if message := invalidTestcasesMessage(testcases); message != "" {
+		t.Errorf("unexpected message: %s", message)
+	}
+	for _, name := range []string{"01", "02", "03", "04", "05", "06", "07"} {
+		testcases = append(testcases, TestcaseValidation{Name: name, Valid: name == "01"})
+	}
+	message := invalidTestcasesMessage(testcases)
+	if message != "6 testcase(s) violate the input constraints: 02, 03, 04, 05, 06, ..." {
+		t.Errorf("unexpected message: %s", message)
+	}
+	if testcases[6].Name != "07" {
+		t.Errorf("testcases must not be modified: %+v", testcases)
+	}
+}
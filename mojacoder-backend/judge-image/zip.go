@@ -2,12 +2,24 @@ package main
 
 import (
 	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+var ZIP_MAGIC = []byte("PK\x03\x04")
+
+const SUBMISSION_ARCHIVE_PATH = "/tmp/mojacoder-submission.zip"
+const SUBMISSION_ARCHIVE_MAX_FILES = 256
+const SUBMISSION_ARCHIVE_MAX_SIZE = 4 * 1024 * 1024
+
+var errInvalidArchive = errors.New("invalid archive")
+
 func unzip(src, dest string) error {
 	const errorMessage = "Failed to unzip an archive: %v"
 	var err error
@@ -25,6 +37,9 @@ func unzip(src, dest string) error {
 		defer fileReader.Close()
 
 		path := filepath.Join(dest, file.Name)
+		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf(errorMessage, fmt.Errorf("illegal file path: %s", file.Name))
+		}
 		if !file.FileInfo().IsDir() {
 			dirname, _ := filepath.Split(path)
 			os.MkdirAll(dirname, file.Mode())
@@ -42,3 +57,173 @@ func unzip(src, dest string) error {
 	}
 	return nil
 }
+
+// zipDirectory は src 以下の通常ファイルを src からの相対パスで dest の zip にまとめる
+func zipDirectory(src, dest string) error {
+	const errorMessage = "Failed to zip a directory: %v"
+	output, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	defer output.Close()
+	zipWriter := zip.NewWriter(output)
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		name, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		writer, err := zipWriter.Create(filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(writer, file)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	if err = zipWriter.Close(); err != nil {
+		return fmt.Errorf(errorMessage, err)
+	}
+	return nil
+}
+
+// normalizeSubmittedArchive は提出ファイルが zip かどうかを判定する。
+// GraphQL 経由の提出は文字列なので、base64 でエンコードされた zip はデコードして書き戻す。
+func normalizeSubmittedArchive(path string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if bytes.HasPrefix(content, ZIP_MAGIC) {
+		return true, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || !bytes.HasPrefix(decoded, ZIP_MAGIC) {
+		return false, nil
+	}
+	return true, os.WriteFile(path, decoded, 0600)
+}
+
+// unzipSubmission は利用者が提出した zip を展開する。
+// 展開先の外を指すパス、通常ファイル以外、既存ファイルの上書き、上限を超える数やサイズは errInvalidArchive として拒否する。
+func unzipSubmission(src, dest string) error {
+	zipReader, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidArchive, err)
+	}
+	defer zipReader.Close()
+	if len(zipReader.File) > SUBMISSION_ARCHIVE_MAX_FILES {
+		return fmt.Errorf("%w: too many files (max %d)", errInvalidArchive, SUBMISSION_ARCHIVE_MAX_FILES)
+	}
+
+	var size int64
+	for _, file := range zipReader.File {
+		path := filepath.Join(dest, file.Name)
+		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("%w: illegal file path: %s", errInvalidArchive, file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			if err = createSubmissionDirectory(dest, path); err != nil {
+				return err
+			}
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			return fmt.Errorf("%w: unsupported file type: %s", errInvalidArchive, file.Name)
+		}
+		if err = createSubmissionDirectory(dest, filepath.Dir(path)); err != nil {
+			return err
+		}
+		written, err := extractZipFile(file, path, SUBMISSION_ARCHIVE_MAX_SIZE-size+1)
+		if err != nil {
+			return err
+		}
+		size += written
+		if size > SUBMISSION_ARCHIVE_MAX_SIZE {
+			return fmt.Errorf("%w: archive is too large (max %d bytes)", errInvalidArchive, SUBMISSION_ARCHIVE_MAX_SIZE)
+		}
+	}
+	return nil
+}
+
+// createSubmissionDirectory は dest から path までのディレクトリを、コンパイラが生成物を書き込めるようにサンドボックス用の権限で作る
+func createSubmissionDirectory(dest, path string) error {
+	relative, err := filepath.Rel(dest, path)
+	if err != nil {
+		return err
+	}
+	current := dest
+	if err = createSandboxDirectory(current); err != nil {
+		return err
+	}
+	for _, name := range strings.Split(relative, string(os.PathSeparator)) {
+		if name == "." {
+			continue
+		}
+		current = filepath.Join(current, name)
+		if err = createSandboxDirectory(current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(file *zip.File, path string, limit int64) (int64, error) {
+	fileReader, err := file.Open()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errInvalidArchive, err)
+	}
+	defer fileReader.Close()
+	output, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return 0, fmt.Errorf("%w: duplicated file: %s", errInvalidArchive, file.Name)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer output.Close()
+	written, err := io.Copy(output, io.LimitReader(fileReader, limit))
+	if err != nil {
+		return written, fmt.Errorf("%w: %v", errInvalidArchive, err)
+	}
+	return written, nil
+}
+
+// extractSubmittedArchive は提出されたコードが zip であれば dir に展開し、エントリーファイルがあるか確かめる。
+// 展開できなかった場合は利用者に返すメッセージとともに false を返す。
+func extractSubmittedArchive(dir, entryFilename string) (bool, string, error) {
+	entryPath := filepath.Join(dir, entryFilename)
+	isArchive, err := normalizeSubmittedArchive(entryPath)
+	if err != nil {
+		return false, "", err
+	}
+	if !isArchive {
+		return true, "", nil
+	}
+	if err = os.Rename(entryPath, SUBMISSION_ARCHIVE_PATH); err != nil {
+		return false, "", err
+	}
+	defer os.Remove(SUBMISSION_ARCHIVE_PATH)
+	err = unzipSubmission(SUBMISSION_ARCHIVE_PATH, dir)
+	if errors.Is(err, errInvalidArchive) {
+		return false, err.Error(), nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	if _, err = os.Stat(entryPath); os.IsNotExist(err) {
+		return false, fmt.Sprintf("%s not found in the archive.", entryFilename), nil
+	} else if err != nil {
+		return false, "", err
+	}
+	return true, "", nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func createZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, content := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestNormalizeSubmittedArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := createZip(t, map[string]string{"00_sample.txt": "42\n"})
+	cases := []struct {
+		name    string
+		content []byte
+		want    bool
+	}{
+		{"raw.zip", archive, true},
+		{"encoded.zip", []byte(base64.StdEncoding.EncodeToString(archive) + "\n"), true},
+		{"plain.txt", []byte("42\n"), false},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, c.name)
+		if err := os.WriteFile(path, c.content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := normalizeSubmittedArchive(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("normalizeSubmittedArchive(%s) = %v; expected %v", c.name, got, c.want)
+		}
+		if got {
+			if err := unzip(path, filepath.Join(dir, c.name+".d")); err != nil {
+				t.Errorf("unzip(%s) failed: %v", c.name, err)
+			}
+		}
+	}
+}
+
+func TestUnzipRejectsPathTraversal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "evil.zip")
+	if err := os.WriteFile(path, createZip(t, map[string]string{"../evil.txt": "evil"}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := unzip(path, filepath.Join(dir, "dest")); err == nil {
+		t.Fatal("unzip accepted a path outside of the destination")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil.txt")); !os.IsNotExist(err) {
+		t.Fatal("unzip wrote a file outside of the destination")
+	}
+}
+
+func TestExtractSubmittedArchive(t *testing.T) {
+	cases := []struct {
+		name    string
+		files   map[string]string
+		want    bool
+		message string
+	}{
+		{"project", map[string]string{"main.cpp": "#include \"lib/a.h\"", "lib/a.h": ""}, true, ""},
+		{"no entry", map[string]string{"other.cpp": ""}, false, "main.cpp not found in the archive."},
+		{"traversal", map[string]string{"main.cpp": "", "../evil.h": ""}, false, "invalid archive: illegal file path: ../evil.h"},
+		{"grader overwrite", map[string]string{"main.cpp": "", "grader.cpp": ""}, false, "invalid archive: duplicated file: grader.cpp"},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "grader.cpp"), []byte("grader"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.cpp"), createZip(t, c.files), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, message, err := extractSubmittedArchive(dir, "main.cpp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want || message != c.message {
+			t.Errorf("%s: extractSubmittedArchive = (%v, %q); expected (%v, %q)", c.name, got, message, c.want, c.message)
+		}
+	}
+}
+
+func TestUnzipSubmissionRejectsLargeArchive(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "large.zip")
+	large := string(make([]byte, SUBMISSION_ARCHIVE_MAX_SIZE+1))
+	if err := os.WriteFile(path, createZip(t, map[string]string{"main.cpp": large}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := unzipSubmission(path, filepath.Join(dir, "dest")); !errors.Is(err, errInvalidArchive) {
+		t.Fatalf("unzipSubmission = %v; expected errInvalidArchive", err)
+	}
+}
+
+func TestZipDirectory(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "testcases")
+	for name, content := range map[string]string{"in/01.txt": "1 2\n", "out/01.txt": "3\n"} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := filepath.Join(dir, "testcases.zip")
+	if err := zipDirectory(src, archive); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(dir, "extracted")
+	if err := unzip(archive, dest); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dest, "out", "01.txt"))
+	if err != nil || string(content) != "3\n" {
+		t.Errorf("unexpected content: %q %v", content, err)
+	}
+}
@@ -1,5 +1,5 @@
 import { S3Handler } from 'aws-lambda'
-import { DynamoDB, S3 } from 'aws-sdk'
+import { DynamoDB, S3, SQS } from 'aws-sdk'
 import * as JSZip from 'jszip'
 import join from 'url-join'
 import { posix, sep } from 'path'
@@ -17,17 +17,64 @@ const TESTCASES_FOR_VIEW_BUCKET_NAME = process.env.TESTCASES_FOR_VIEW_BUCKET_NAM
 if(TESTCASES_FOR_VIEW_BUCKET_NAME === undefined) throw "TESTCASES_FOR_VIEW_BUCKET_NAME is not defined.";
 const JUDGECODES_BUCKET_NAME = process.env.JUDGECODES_BUCKET_NAME as string;
 if(JUDGECODES_BUCKET_NAME === undefined) throw "JUDGECODES_BUCKET_NAME is not defined.";
+const JUDGEQUEUE_URL = process.env.JUDGEQUEUE_URL as string;
+if(JUDGEQUEUE_URL === undefined) throw "JUDGEQUEUE_URL is not defined.";
 
 const dynamodb = new DynamoDB({apiVersion: '2012-08-10'});
 const s3 = new S3({apiVersion: '2006-03-01'});
+const sqs = new SQS({apiVersion: '2012-11-05'});
 
-type JudgeType = "NORMAL" | "SPECIAL";
+type JudgeType = "NORMAL" | "SPECIAL" | "INTERACTIVE";
+type ProblemType = "NORMAL" | "OUTPUT_ONLY" | "GRADER";
+type JudgePolicy = "STOP_ON_TLE" | "RUN_ALL" | "STOP_ON_FAILURE" | "STOP_PER_SUBTASK";
+interface Subtask {
+    name: string
+    testcases: string[]
+}
+type SolutionExpectation = "AC" | "WA" | "TLE" | "MLE" | "RE" | "FAIL";
+const SOLUTION_EXPECTATIONS: SolutionExpectation[] = ["AC", "WA", "TLE", "MLE", "RE", "FAIL"]
+const MAX_SOLUTIONS = 16
+interface SolutionConfig {
+    file: string
+    lang: string
+    expected?: SolutionExpectation
+}
+interface Solution {
+    name: string
+    lang: string
+    expected: SolutionExpectation
+    code: Buffer
+}
+interface GeneratorConfig {
+    lang: string
+    solution: {
+        file: string
+        lang: string
+    }
+}
+interface Generator {
+    lang: string
+    code: Buffer
+    script: string
+    solution: {
+        name: string
+        lang: string
+        code: Buffer
+    }
+}
 interface Config {
     title: string,
     notListed?: boolean,
     difficulty?: string,
     judgeType?: JudgeType
     judgeLang?: string
+    problemType?: ProblemType
+    judgePolicy?: JudgePolicy
+    subtasks?: Subtask[]
+    samples?: string[]
+    solutions?: SolutionConfig[]
+    validatorLang?: string
+    generator?: GeneratorConfig
 }
 
 interface Problem {
@@ -42,6 +89,53 @@ interface Problem {
     judgeType: JudgeType
     judgeLang: string
     judgeCode: string | null
+    problemType: ProblemType
+    graders: { [lang: string]: Buffer }
+    judgePolicy: JudgePolicy
+    subtasks: Subtask[]
+    samples: string[]
+    solutions: Solution[]
+    validator: string | null
+    validatorLang: string
+    generator: Generator | null
+}
+
+function parseGeneratorScript(script: string): string[] {
+    const names: string[] = []
+    const lines = script.split('\n')
+    for(let index = 0; index < lines.length; index++) {
+        const fields = lines[index].trim().split(/\s+/)
+        if(fields[0] === '' || fields[0].startsWith('#')) continue
+        const name = fields[0]
+        if(name === '.' || name === '..' || /[\/\\]/.test(name)) throw `Invalid testcase name '${name}' in the generator script (line ${index + 1}).`
+        if(names.includes(name)) throw `Duplicated testcase '${name}' in the generator script (line ${index + 1}).`
+        names.push(name)
+    }
+    return names
+}
+
+async function parseGenerator(zip: JSZip, config: GeneratorConfig | undefined): Promise<Generator | null> {
+    const generatorFile = zip.file("generator")
+    if(!config) {
+        if(generatorFile) throw "generator is required in problem.json for the generator."
+        return null
+    }
+    if(generatorFile === null) throw "Generator not found."
+    const scriptFile = zip.file("generator_script.txt")
+    if(scriptFile === null) throw "Generator script 'generator_script.txt' not found."
+    if(!config.solution) throw "A solution is required for the generator."
+    const solutionFile = zip.file(config.solution.file)
+    if(solutionFile === null || solutionFile.dir) throw `Solution '${config.solution.file}' not found.`
+    return {
+        lang: config.lang,
+        code: await generatorFile.async("nodebuffer"),
+        script: await scriptFile.async("string"),
+        solution: {
+            name: config.solution.file,
+            lang: config.solution.lang,
+            code: await solutionFile.async("nodebuffer"),
+        },
+    }
 }
 
 async function parseZip(data: Buffer): Promise<Problem> {
@@ -69,7 +163,7 @@ async function parseZip(data: Buffer): Promise<Problem> {
     }
     const configFile = zip.file('problem.json');
     if(configFile === null) throw "Config not fonud.";
-    const { title, notListed, difficulty, judgeType, judgeLang } = JSON.parse(await configFile.async("string")) as Config;
+    const { title, notListed, difficulty, judgeType, judgeLang, problemType, judgePolicy, subtasks, samples, solutions: solutionConfigs, validatorLang, generator: generatorConfig } = JSON.parse(await configFile.async("string")) as Config;
     const statementFile = zip.file('README.md');
     if(statementFile === null) throw "Statement not found.";
     const statement = await statementFile.async("string");
@@ -82,7 +176,35 @@ async function parseZip(data: Buffer): Promise<Problem> {
     if(judgeCodeFile) judgeCode = await judgeCodeFile.async("string");
     else judgeCode = null;
     if(judgeType && judgeType !== "NORMAL"  && judgeCode === null) throw "Judge code is required for special judge."
-    const testcasesDir = zip.folder('testcases');
+    const validatorFile = zip.file("validator");
+    let validator: string | null;
+    if(validatorFile) validator = await validatorFile.async("string");
+    else validator = null;
+    if(validator !== null && !validatorLang) throw "validatorLang is required for the validator."
+    if(problemType === "OUTPUT_ONLY" && judgeType === "INTERACTIVE") throw "Output only problems can't be interactive."
+    const generator = await parseGenerator(zip, generatorConfig)
+    if(generator !== null && (problemType === "GRADER" || judgeType === "INTERACTIVE")) throw "Generators can't be used for grader or interactive problems."
+    const graders: { [lang: string]: Buffer } = {}
+    if(problemType === "GRADER") {
+        const gradersDir = zip.folder('graders')
+        if(gradersDir === null) throw "Grader directory 'graders' not found."
+        const langs = new Set<string>()
+        gradersDir.forEach((path) => langs.add(path.split('/')[0]))
+        for(const lang of langs) {
+            const graderDir = gradersDir.folder(lang)
+            if(graderDir === null) continue
+            graders[lang] = await graderDir.generateAsync({
+                type: "nodebuffer",
+            })
+        }
+        if(Object.keys(graders).length === 0) throw "No graders found."
+    }
+    let testcasesDir = zip.folder('testcases');
+    if(testcasesDir === null && generator !== null) {
+        testcasesDir = new JSZip()
+        testcasesDir.folder('in')
+        testcasesDir.folder('out')
+    }
     if(testcasesDir === null) throw "Testcases not found.";
     const testcases = await testcasesDir.generateAsync({
         type: "nodebuffer",
@@ -102,6 +224,34 @@ async function parseZip(data: Buffer): Promise<Problem> {
         if(outTestcaseFile === null || outTestcaseFile.dir) return
         testcaseNames.push(path)
     })
+    for(const name of generator === null ? [] : parseGeneratorScript(generator.script)) {
+        if(testcaseNames.includes(name)) throw `Testcase '${name}' is both in 'testcases' and the generator script.`
+        testcaseNames.push(name)
+    }
+    if(judgePolicy === "STOP_PER_SUBTASK" && (subtasks === undefined || subtasks.length === 0)) throw "Subtasks are required for STOP_PER_SUBTASK."
+    for(const subtask of subtasks || []) {
+        for(const testcase of subtask.testcases) {
+            if(!testcaseNames.includes(testcase)) throw `Testcase '${testcase}' in subtask '${subtask.name}' not found.`
+        }
+    }
+    for(const sample of samples || []) {
+        if(!testcaseNames.includes(sample)) throw `Sample '${sample}' not found.`
+    }
+    if(problemType === "OUTPUT_ONLY" && solutionConfigs && solutionConfigs.length > 0) throw "Output only problems can't have reference solutions."
+    if(solutionConfigs && solutionConfigs.length > MAX_SOLUTIONS) throw `Too many solutions (at most ${MAX_SOLUTIONS}).`
+    const solutions: Solution[] = []
+    for(const solution of solutionConfigs || []) {
+        const expected = solution.expected || "AC"
+        if(!SOLUTION_EXPECTATIONS.includes(expected)) throw `Unknown expected verdict '${expected}' for solution '${solution.file}'.`
+        const solutionFile = zip.file(solution.file)
+        if(solutionFile === null || solutionFile.dir) throw `Solution '${solution.file}' not found.`
+        solutions.push({
+            name: solution.file,
+            lang: solution.lang,
+            expected,
+            code: await solutionFile.async("nodebuffer"),
+        })
+    }
     return {
         title,
         notListed: notListed || false,
@@ -113,12 +263,37 @@ async function parseZip(data: Buffer): Promise<Problem> {
         testcaseNames,
         judgeType: judgeType || "NORMAL",
         judgeLang: judgeLang || "",
-        judgeCode
+        judgeCode,
+        problemType: problemType || "NORMAL",
+        graders,
+        judgePolicy: judgePolicy || "STOP_ON_TLE",
+        subtasks: subtasks || [],
+        samples: samples || [],
+        solutions,
+        validator,
+        validatorLang: validator === null ? "" : validatorLang || "",
+        generator,
+    }
+}
+
+function subtasksToDynamoDB(subtasks: Subtask[]): DynamoDB.AttributeValue {
+    return {
+        L: subtasks.map((subtask) => ({
+            M: {
+                name: { S: subtask.name },
+                testcases: { L: subtask.testcases.map((name) => ({ S: name })) },
+            },
+        })),
     }
 }
 
-async function uploadToS3(problemID: string, testcases: Buffer, testcasesDir: JSZip, judgeCode: string | null) {
-    await s3.putObject({ Bucket: TESTCASES_BUCKET_NAME, Key: problemID + '.zip', Body: testcases }).promise()
+async function uploadToS3(problemID: string, testcases: Buffer, testcasesDir: JSZip, judgeCode: string | null, graders: { [lang: string]: Buffer }, solutions: Solution[], validator: string | null, generator: Generator | null) {
+    const generatorTestcases = join(problemID, 'generator', 'testcases.zip')
+    if(generator) {
+        await s3.putObject({ Bucket: JUDGECODES_BUCKET_NAME, Key: generatorTestcases, Body: testcases }).promise()
+    } else {
+        await s3.putObject({ Bucket: TESTCASES_BUCKET_NAME, Key: problemID + '.zip', Body: testcases }).promise()
+    }
     const inTestcases = testcasesDir.folder('in')!
     const outTestcases = testcasesDir.folder('out')!
     const inTestcaseFiles = inTestcases.filter((_, file) => !file.dir)
@@ -134,6 +309,31 @@ async function uploadToS3(problemID: string, testcases: Buffer, testcasesDir: JS
     if(judgeCode){
         await s3.putObject({ Bucket: JUDGECODES_BUCKET_NAME, Key: problemID, Body: judgeCode }).promise()
     }
+    if(validator) {
+        await s3.putObject({ Bucket: JUDGECODES_BUCKET_NAME, Key: join(problemID, 'validator'), Body: validator }).promise()
+    }
+    for(const lang of Object.keys(graders)) {
+        await s3.putObject({ Bucket: JUDGECODES_BUCKET_NAME, Key: join(problemID, 'graders', lang + '.zip'), Body: graders[lang] }).promise()
+    }
+    const manifest = []
+    for(let index = 0; index < solutions.length; index++) {
+        const solution = solutions[index]
+        const key = join(problemID, 'solutions', String(index))
+        await s3.putObject({ Bucket: JUDGECODES_BUCKET_NAME, Key: key, Body: solution.code }).promise()
+        manifest.push({ name: solution.name, lang: solution.lang, expected: solution.expected, key })
+    }
+    await s3.putObject({ Bucket: JUDGECODES_BUCKET_NAME, Key: join(problemID, 'solutions.json'), Body: JSON.stringify(manifest) }).promise()
+    let generatorManifest = null
+    if(generator) {
+        const key = join(problemID, 'generator', 'program')
+        const script = join(problemID, 'generator', 'script')
+        const solution = join(problemID, 'generator', 'solution')
+        await s3.putObject({ Bucket: JUDGECODES_BUCKET_NAME, Key: key, Body: generator.code }).promise()
+        await s3.putObject({ Bucket: JUDGECODES_BUCKET_NAME, Key: script, Body: generator.script }).promise()
+        await s3.putObject({ Bucket: JUDGECODES_BUCKET_NAME, Key: solution, Body: generator.solution.code }).promise()
+        generatorManifest = { lang: generator.lang, key, script, testcases: generatorTestcases, solution: { name: generator.solution.name, lang: generator.solution.lang, expected: "AC", key: solution } }
+    }
+    await s3.putObject({ Bucket: JUDGECODES_BUCKET_NAME, Key: join(problemID, 'generator.json'), Body: JSON.stringify(generatorManifest) }).promise()
 }
 
 async function deployProblem(key: string): Promise<void> {
@@ -145,6 +345,8 @@ async function deployProblem(key: string): Promise<void> {
     const keyPath = posix.parse(key);
     const userID = keyPath.dir;
     const status = problem.notListed ? 'CREATED_NOT_LISTED' : 'CREATED'
+    const testcasesStatus = problem.generator === null ? 'READY' : 'GENERATING'
+    const testcasesVersion = uuid()
     const slug = decodeURIComponent(keyPath.name);
     const slugRecord = await dynamodb.getItem({
         TableName: SLUG_TABLE_NAME,
@@ -200,9 +402,28 @@ async function deployProblem(key: string): Promise<void> {
                 },
                 ":judgeLang": {
                     S: problem.judgeLang
-                }
+                },
+                ":problemType": {
+                    S: problem.problemType
+                },
+                ":judgePolicy": {
+                    S: problem.judgePolicy
+                },
+                ":subtasks": subtasksToDynamoDB(problem.subtasks),
+                ":samples": {
+                    L: problem.samples.map((name) => ({ S: name })),
+                },
+                ":validatorLang": {
+                    S: problem.validatorLang
+                },
+                ":testcasesStatus": {
+                    S: testcasesStatus
+                },
+                ":testcasesVersion": {
+                    S: testcasesVersion
+                },
             },
-            UpdateExpression: "SET title = :title, #status = :status, statement = :statement, hasEditorial = :hasEditorial, editorial = :editorial, hasDifficulty = :hasDifficulty, difficulty = :difficulty, testcaseNames = :testcaseNames, judgeType = :judgeType, judgeLang = :judgeLang",
+            UpdateExpression: "SET title = :title, #status = :status, statement = :statement, hasEditorial = :hasEditorial, editorial = :editorial, hasDifficulty = :hasDifficulty, difficulty = :difficulty, testcaseNames = :testcaseNames, judgeType = :judgeType, judgeLang = :judgeLang, problemType = :problemType, judgePolicy = :judgePolicy, subtasks = :subtasks, samples = :samples, validatorLang = :validatorLang, testcasesStatus = :testcasesStatus, testcasesVersion = :testcasesVersion REMOVE validation, testcasesKey",
         }).promise();
     } else {
         problemID = uuid();
@@ -280,6 +501,25 @@ async function deployProblem(key: string): Promise<void> {
                             judgeLang: {
                                 S: problem.judgeLang
                             },
+                            problemType: {
+                                S: problem.problemType
+                            },
+                            judgePolicy: {
+                                S: problem.judgePolicy
+                            },
+                            subtasks: subtasksToDynamoDB(problem.subtasks),
+                            samples: {
+                                L: problem.samples.map((name) => ({ S: name })),
+                            },
+                            validatorLang: {
+                                S: problem.validatorLang
+                            },
+                            testcasesStatus: {
+                                S: testcasesStatus
+                            },
+                            testcasesVersion: {
+                                S: testcasesVersion
+                            },
                         },
                         ConditionExpression: 'attribute_not_exists(#id)',
                         ExpressionAttributeNames: {
@@ -290,7 +530,13 @@ async function deployProblem(key: string): Promise<void> {
             ],
         }).promise();
     }
-    await uploadToS3(problemID, problem.testcases, problem.testcasesDir, problem.judgeCode);
+    await uploadToS3(problemID, problem.testcases, problem.testcasesDir, problem.judgeCode, problem.graders, problem.solutions, problem.validator, problem.generator);
+    if(problem.generator) {
+        await sqs.sendMessage({
+            QueueUrl: JUDGEQUEUE_URL,
+            MessageBody: JSON.stringify({ type: "GENERATE_TESTCASES", problemID, userID }),
+        }).promise();
+    }
 }
 
 export const handler: S3Handler = async (event) => {
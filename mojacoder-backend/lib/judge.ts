@@ -6,12 +6,16 @@ import { SubnetType, Vpc } from '@aws-cdk/aws-ec2';
 import { AwsLogDriver, Cluster, ContainerImage, FargateService, FargateTaskDefinition, LinuxParameters } from '@aws-cdk/aws-ecs';
 import { Bucket } from '@aws-cdk/aws-s3'
 import { GraphqlApi, MappingTemplate } from '@aws-cdk/aws-appsync';
+import { NodejsFunction } from '@aws-cdk/aws-lambda-nodejs';
 import { join } from 'path';
 
 export interface JudgeProps {
     api: GraphqlApi
     testcases: Bucket
+    testcasesForView: Bucket
     judgeCodes: Bucket
+    problemTable: Table
+    postedProblemsCreatedNotification: NodejsFunction
 }
 
 export class Judge extends cdk.Construct {
@@ -26,6 +30,11 @@ export class Judge extends cdk.Construct {
                 maxReceiveCount: 4,
             }
         });
+        props.postedProblemsCreatedNotification.addEnvironment('JUDGEQUEUE_URL', JudgeQueue.queueUrl);
+        props.postedProblemsCreatedNotification.addToRolePolicy(new PolicyStatement({
+            actions: ['sqs:SendMessage'],
+            resources: [JudgeQueue.queueArn],
+        }));
         this.submissionTable = new Table(this, 'submissionTable', {
             billingMode: BillingMode.PAY_PER_REQUEST,
             partitionKey: {
@@ -73,11 +82,19 @@ export class Judge extends cdk.Construct {
         }));
         JudgeUser.addToPolicy(new PolicyStatement({
             resources: [JudgeQueue.queueArn],
-            actions: ['sqs:ReceiveMessage', 'sqs:DeleteMessage'],
+            actions: ['sqs:ReceiveMessage', 'sqs:DeleteMessage', 'sqs:SendMessage', 'sqs:ChangeMessageVisibility'],
         }));
         JudgeUser.addToPolicy(new PolicyStatement({
             resources: [this.submissionTable.tableArn],
-            actions: ['dynamodb:UpdateItem'],
+            actions: ['dynamodb:GetItem', 'dynamodb:UpdateItem'],
+        }));
+        JudgeUser.addToPolicy(new PolicyStatement({
+            resources: [props.problemTable.tableArn],
+            actions: ['dynamodb:GetItem', 'dynamodb:UpdateItem'],
+        }));
+        JudgeUser.addToPolicy(new PolicyStatement({
+            resources: [this.submissionTable.tableArn + '/index/problemID-index'],
+            actions: ['dynamodb:Query'],
         }));
         JudgeUser.addToPolicy(new PolicyStatement({
             resources: [playgroundCodeBucket.bucketArn + '/*'],
@@ -87,6 +104,14 @@ export class Judge extends cdk.Construct {
             resources: [submittedCodeBucket.bucketArn + '/*', props.testcases.bucketArn + '/*', props.judgeCodes.bucketArn + '/*'],
             actions: ['s3:GetObject'],
         }));
+        JudgeUser.addToPolicy(new PolicyStatement({
+            resources: [props.testcases.bucketArn + '/*', props.testcasesForView.bucketArn + '/*'],
+            actions: ['s3:PutObject'],
+        }));
+        JudgeUser.addToPolicy(new PolicyStatement({
+            resources: [props.judgeCodes.bucketArn],
+            actions: ['s3:ListBucket'],
+        }));
         const accessKey = new CfnAccessKey(this, 'JudgeUserAccessKey', {
             userName: JudgeUser.userName,
         });
@@ -116,9 +141,11 @@ export class Judge extends cdk.Construct {
                 API_ENDPOINT: props.api.graphqlUrl,
                 JUDGEQUEUE_URL: JudgeQueue.queueUrl,
                 SUBMISSION_TABLE_NAME: this.submissionTable.tableName,
+                PROBLEM_TABLE_NAME: props.problemTable.tableName,
                 PLAYGROUND_CODE_BUCKET_NAME: playgroundCodeBucket.bucketName,
                 SUBMITTED_CODE_BUCKET_NAME: submittedCodeBucket.bucketName,
                 TESTCASES_BUCKET_NAME: props.testcases.bucketName,
+                TESTCASES_FOR_VIEW_BUCKET_NAME: props.testcasesForView.bucketName,
                 JUDGECODES_BUCKET_NAME: props.judgeCodes.bucketName,
             },
         });
@@ -181,6 +208,12 @@ export class Judge extends cdk.Construct {
             responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/code/response.vtl')),
         })
         const submissionTableDataSource = props.api.addDynamoDbDataSource('submission_table', this.submissionTable);
+        const problemTableDataSource = props.api.addDynamoDbDataSource('owned_problem_table', props.problemTable);
+        const testcasesReadyFunction = problemTableDataSource.createFunction({
+            name: 'testcasesReady',
+            requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/testcasesReady/request.vtl')),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/testcasesReady/response.vtl')),
+        });
         const submitCodePutItemFunction = submissionTableDataSource.createFunction({
             name: 'submitCodePutItem',
             requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/submitCode/putItem/request.vtl')),
@@ -202,7 +235,7 @@ export class Judge extends cdk.Construct {
         props.api.createResolver({
             typeName: 'Mutation',
             fieldName: 'submitCode',
-            pipelineConfig: [submitCodePutItemFunction, submitCodePutObjectFunction, submitCodeSendMessageFunction],
+            pipelineConfig: [testcasesReadyFunction, submitCodePutItemFunction, submitCodePutObjectFunction, submitCodeSendMessageFunction],
             requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/submitCode/request.vtl')),
             responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/submitCode/response.vtl')),
         });
@@ -248,6 +281,21 @@ export class Judge extends cdk.Construct {
             requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/runPlayground/request.vtl')),
             responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/runPlayground/response.vtl')),
         });
+        const runSampleTestSendMessageFunction = judgeQueueDatasource.createFunction({
+            name: 'runSampleTestSendMessage',
+            requestMappingTemplate: MappingTemplate.fromString(
+                MappingTemplate.fromFile(join(__dirname, '../graphql/runSampleTest/sendMessage/request.vtl')).renderTemplate()
+                    .replace(/%QUEUE_URL%/g, JudgeQueue.queueUrl)
+            ),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/runSampleTest/sendMessage/response.vtl')),
+        });
+        props.api.createResolver({
+            typeName: 'Mutation',
+            fieldName: 'runSampleTest',
+            pipelineConfig: [testcasesReadyFunction, runPlaygroundPutObjectFunction, runSampleTestSendMessageFunction],
+            requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/runSampleTest/request.vtl')),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/runSampleTest/response.vtl')),
+        });
         const PlaygroundDataSource = props.api.addNoneDataSource('Playground');
         PlaygroundDataSource.createResolver({
             typeName: 'Mutation',
@@ -279,5 +327,72 @@ export class Judge extends cdk.Construct {
             requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/updateSubmission/request.vtl')),
             responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/updateSubmission/response.vtl')),
         });
+        const rejudgeGetSubmissionFunction = submissionTableDataSource.createFunction({
+            name: 'rejudgeGetSubmission',
+            requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/rejudgeSubmission/getSubmission/request.vtl')),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/rejudgeSubmission/getSubmission/response.vtl')),
+        });
+        const ownedProblemFunction = problemTableDataSource.createFunction({
+            name: 'ownedProblem',
+            requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/ownedProblem/request.vtl')),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/ownedProblem/response.vtl')),
+        });
+        const rejudgeCancelJudgingFunction = submissionTableDataSource.createFunction({
+            name: 'rejudgeCancelJudging',
+            requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/rejudgeSubmission/cancelJudging/request.vtl')),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/rejudgeSubmission/cancelJudging/response.vtl')),
+        });
+        const rejudgeSendMessageFunction = judgeQueueDatasource.createFunction({
+            name: 'rejudgeSendMessage',
+            requestMappingTemplate: MappingTemplate.fromString(
+                MappingTemplate.fromFile(join(__dirname, '../graphql/rejudgeSubmission/sendMessage/request.vtl')).renderTemplate()
+                    .replace(/%QUEUE_URL%/g, JudgeQueue.queueUrl)
+            ),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/rejudgeSubmission/sendMessage/response.vtl')),
+        });
+        props.api.createResolver({
+            typeName: 'Mutation',
+            fieldName: 'rejudgeSubmission',
+            pipelineConfig: [rejudgeGetSubmissionFunction, ownedProblemFunction, rejudgeCancelJudgingFunction, rejudgeSendMessageFunction],
+            requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/rejudgeSubmission/request.vtl')),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/rejudgeSubmission/response.vtl')),
+        });
+        props.api.createResolver({
+            typeName: 'Mutation',
+            fieldName: 'rejudgeProblem',
+            pipelineConfig: [ownedProblemFunction, rejudgeSendMessageFunction],
+            requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/rejudgeProblem/request.vtl')),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/rejudgeProblem/response.vtl')),
+        });
+        const validateProblemSendMessageFunction = judgeQueueDatasource.createFunction({
+            name: 'validateProblemSendMessage',
+            requestMappingTemplate: MappingTemplate.fromString(
+                MappingTemplate.fromFile(join(__dirname, '../graphql/validateProblem/sendMessage/request.vtl')).renderTemplate()
+                    .replace(/%QUEUE_URL%/g, JudgeQueue.queueUrl)
+            ),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/validateProblem/sendMessage/response.vtl')),
+        });
+        props.api.createResolver({
+            typeName: 'Mutation',
+            fieldName: 'validateProblem',
+            pipelineConfig: [ownedProblemFunction, validateProblemSendMessageFunction],
+            requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/validateProblem/request.vtl')),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/validateProblem/response.vtl')),
+        });
+        const generateTestcasesSendMessageFunction = judgeQueueDatasource.createFunction({
+            name: 'generateTestcasesSendMessage',
+            requestMappingTemplate: MappingTemplate.fromString(
+                MappingTemplate.fromFile(join(__dirname, '../graphql/generateTestcases/sendMessage/request.vtl')).renderTemplate()
+                    .replace(/%QUEUE_URL%/g, JudgeQueue.queueUrl)
+            ),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/generateTestcases/sendMessage/response.vtl')),
+        });
+        props.api.createResolver({
+            typeName: 'Mutation',
+            fieldName: 'generateTestcases',
+            pipelineConfig: [ownedProblemFunction, generateTestcasesSendMessageFunction],
+            requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/generateTestcases/request.vtl')),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/generateTestcases/response.vtl')),
+        });
     }
 }
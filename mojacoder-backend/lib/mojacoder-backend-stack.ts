@@ -28,7 +28,10 @@ export class MojacoderBackendStack extends cdk.Stack {
         const judge = new Judge(this, 'judge', {
             api: users.api,
             testcases: problems.testcases,
-            judgeCodes: problems.judgeCodes
+            testcasesForView: problems.testcasesForView,
+            judgeCodes: problems.judgeCodes,
+            problemTable: problems.problemTable,
+            postedProblemsCreatedNotification: problems.postedProblemsCreatedNotification,
         })
         new Contest(this, 'contest', {
             api: users.api,
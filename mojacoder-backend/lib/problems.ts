@@ -18,10 +18,13 @@ const S3_ALLOW_ORIGIN: string[] = ['https://mojacoder.app', 'http://localhost:30
 export class Problems extends cdk.Construct {
     public readonly testcases: Bucket
     public readonly judgeCodes: Bucket
+    public readonly testcasesForView: Bucket
+    public readonly problemTable: Table
+    public readonly postedProblemsCreatedNotification: NodejsFunction
 
     constructor(scope: cdk.Construct, id: string, props: ProblemsProps) {
         super(scope, id);
-        const problemTable = new Table(this, 'problem-table', {
+        const problemTable = this.problemTable = new Table(this, 'problem-table', {
             billingMode: BillingMode.PAY_PER_REQUEST,
             partitionKey: {
                 name: 'id',
@@ -140,7 +143,7 @@ export class Problems extends cdk.Construct {
             ]
         });
         this.testcases = new Bucket(this, 'testcases');
-        const testcasesForView = new Bucket(this, 'testcases-for-view', {
+        const testcasesForView = this.testcasesForView = new Bucket(this, 'testcases-for-view', {
             cors: [
                 {
                     allowedMethods: [HttpMethods.GET],
@@ -158,7 +161,7 @@ export class Problems extends cdk.Construct {
                 }
             ],
         });
-        const postedProblemsCreatedNotification = new NodejsFunction(this, 'postedProblemsCreatedNotification', {
+        this.postedProblemsCreatedNotification = new NodejsFunction(this, 'postedProblemsCreatedNotification', {
             entry: join(__dirname, '../lambda/s3-posted-problems-created-notification/index.ts'),
             handler: 'handler',
             runtime: lambda.Runtime.NODEJS_16_X,
@@ -173,11 +176,11 @@ export class Problems extends cdk.Construct {
                 JUDGECODES_BUCKET_NAME: this.judgeCodes.bucketName,
             },
         });
-        postedProblemsCreatedNotification.addToRolePolicy(new PolicyStatement({
+        this.postedProblemsCreatedNotification.addToRolePolicy(new PolicyStatement({
             actions: ['s3:GetObject', 's3:PutObject', 'dynamodb:PutItem', 'dynamodb:UpdateItem', 'dynamodb:GetItem'],
             resources: [postedProblems.bucketArn + '/*', this.testcases.bucketArn + '/*', testcasesForView.bucketArn + '/*', this.judgeCodes.bucketArn + '/*', problemTable.tableArn, slugTable.tableArn],
         }))
-        postedProblems.addObjectCreatedNotification(new LambdaDestination(postedProblemsCreatedNotification), {
+        postedProblems.addObjectCreatedNotification(new LambdaDestination(this.postedProblemsCreatedNotification), {
             suffix: '.zip'
         });
         const issueProblemUploadUrlLambda = new NodejsFunction(this, 'issueProblemUploadUrl', {
@@ -302,6 +305,12 @@ export class Problems extends cdk.Construct {
             requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/problem/request.vtl')),
             responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/problem/response.vtl')),
         });
+        problemTableDataSource.createResolver({
+            typeName: 'Mutation',
+            fieldName: 'updateProblemValidation',
+            requestMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/updateProblemValidation/request.vtl')),
+            responseMappingTemplate: MappingTemplate.fromFile(join(__dirname, '../graphql/updateProblemValidation/response.vtl')),
+        });
         const likeProblemDatasource = props.api.addDynamoDbDataSource('likeProblem', likersTable);
         likeProblemDatasource.grantPrincipal.addToPrincipalPolicy(new PolicyStatement({
             actions: ['dynamodb:UpdateItem'],
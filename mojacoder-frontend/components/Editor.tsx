@@ -65,7 +65,7 @@ const LangAlias = {
     'text/x-go': [/go/],
     python: [/py/],
     'text/x-csrc': [/gcc/, 'c'],
-    'text/x-c++src': [/g\+\+/, 'cpp'],
+    'text/x-c++src': [/g\+\+/, 'cpp', 'cpp-testlib'],
     'text/x-csharp': [/csharp/, 'cs'],
     'text/x-brainfuck': ['bf-20041219', 'bf'],
     'text/plain': ['cat', 'txt', 'text'],
@@ -16,6 +16,7 @@ export interface JudgeStatusBadgeProps {
 export const JudgeStatusColors = {
     WJ: 'secondary',
     CE: 'warning',
+    CLE: 'warning',
     AC: 'success',
     WA: 'warning',
     TLE: 'warning',
@@ -26,6 +27,8 @@ export const JudgeStatusColors = {
     JCE: 'warning',
     JTLE: 'warning',
     JMLE: 'warning',
+    JRE: 'warning',
+    SKIPPED: 'secondary',
 }
 
 const JudgeStatusBadge: React.FC<JudgeStatusBadgeProps> = (props) => {
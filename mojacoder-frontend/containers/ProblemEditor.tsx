@@ -156,6 +156,7 @@ const SpecialJudgeLangs: SelectorOptionsType = [
     { value: 'python', label: 'Python3' },
     { value: 'pypy', label: 'PyPy3' },
     { value: 'cpp', label: 'C++' },
+    { value: 'cpp-testlib', label: 'C++ (testlib)' },
     { value: 'java', label: 'Java' },
     { value: 'rust', label: 'Rust' },
 ]
@@ -541,6 +542,11 @@ const WebEditor: React.FC<WebEditorProps> = ({ data, setZip }) => {
                                 onChange={setJudgeLang}
                                 options={SpecialJudgeLangs}
                             />
+                            <p className="text-muted">
+                                {judgeLang === 'cpp-testlib'
+                                    ? '正解なら終了コード 0、不正解なら 1 または 2 (testlib) で終了してください。それ以外の終了コードやシグナルでの終了はジャッジの異常 (JRE) になります。'
+                                    : '正解なら終了コード 0、不正解なら 0 以外の終了コードで終了してください。シグナルでの終了はジャッジの異常 (JRE) になります。'}
+                            </p>
                             <Editor
                                 lang={judgeLang}
                                 lineNumbers
@@ -4,6 +4,7 @@ import { JudgeStatusBadgeProgress } from '../components/JudgeStatusBadge'
 export const JudgeStatus = {
     WJ: 'WJ',
     CE: 'CE',
+    CLE: 'CLE',
     AC: 'AC',
     WA: 'WA',
     TLE: 'TLE',
@@ -14,6 +15,8 @@ export const JudgeStatus = {
     JCE: 'JCE',
     JMLE: 'JMLE',
     JTLE: 'JTLE',
+    JRE: 'JRE',
+    SKIPPED: 'SKIPPED',
 } as const
 export type JudgeStatus = typeof JudgeStatus[keyof typeof JudgeStatus]
 
@@ -27,6 +30,7 @@ export interface JudgeStatusDetail {
 export const JudgeStatusToText = {
     WJ: 'ジャッジ待ち',
     CE: 'コンパイルエラー',
+    CLE: 'コンパイル制限超過',
     AC: '正解',
     WA: '不正解',
     TLE: '実行時間制限超過',
@@ -37,6 +41,8 @@ export const JudgeStatusToText = {
     JCE: 'ジャッジコンパイルエラー',
     JMLE: 'ジャッジメモリ制限超過',
     JTLE: 'ジャッジ実行時間制限超過',
+    JRE: 'ジャッジ実行時エラー',
+    SKIPPED: 'スキップ',
 }
 
 type WholeJudgeStatus = {
@@ -56,6 +62,9 @@ export function getJudgeStatusFromTestcases(
     if (status === SubmissionStatus.CE) {
         return { wholeStatus: JudgeStatus.CE, progress: null }
     }
+    if (status === SubmissionStatus.CLE) {
+        return { wholeStatus: JudgeStatus.CLE, progress: null }
+    }
     if (status === SubmissionStatus.WJ && testcases.length === 0) {
         return { wholeStatus: JudgeStatus.WJ, progress: null }
     }
@@ -73,7 +82,8 @@ export function getJudgeStatusFromTestcases(
         if (testcase.status !== JudgeStatus.WJ) progress.current++
         if (
             testcase.status !== JudgeStatus.WJ &&
-            testcase.status !== JudgeStatus.AC
+            testcase.status !== JudgeStatus.AC &&
+            testcase.status !== JudgeStatus.SKIPPED
         ) {
             wholeStatus = testcase.status
         }
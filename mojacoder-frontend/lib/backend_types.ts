@@ -66,6 +66,7 @@ export interface ProblemDetail {
 export const SubmissionStatus = {
     WJ: 'WJ',
     CE: 'CE',
+    CLE: 'CLE',
     JUDGED: 'JUDGED',
     IE: 'IE',
     JCE: 'JCE',
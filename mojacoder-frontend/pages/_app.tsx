@@ -63,11 +63,14 @@ const languages = {
             run: '実行',
             signInRequired: 'コードを実行するにはサインインしてください。',
             runningCode: 'コードを実行中です。しばらくお待ち下さい。',
+            status: '結果',
             exitCode: '終了コード',
+            signal: 'シグナル',
             time: '実行時間',
             memory: 'メモリ',
             stdout: '標準出力',
             stderr: '標準エラー出力',
+            compileOutput: 'コンパイラの出力',
         },
         user: {
             userNotFound: 'ユーザーが存在しません。',
@@ -221,11 +224,14 @@ const languages = {
             run: 'Run',
             signInRequired: 'Signing in is required to run code.',
             runningCode: 'Running your code...',
+            status: 'Status',
             exitCode: 'Exit Code',
+            signal: 'Signal',
             time: 'Time',
             memory: 'Memory',
             stdout: 'Standard Output',
             stderr: 'Standard Error Output',
+            compileOutput: 'Compiler Output',
         },
         user: {
             userNotFound: 'User not found.',
@@ -27,11 +27,14 @@ type Status = typeof Status[keyof typeof Status]
 const SUBSCRIPTION_DOCUMENT = gql`
     subscription onResponsePlayground($sessionID: ID!, $userID: ID!) {
         onResponsePlayground(sessionID: $sessionID, userID: $userID) {
+            status
             exitCode
+            signal
             time
             memory
             stderr
             stdout
+            compileOutput
         }
     }
 `
@@ -45,11 +48,14 @@ const MUTATION_DOCUMENT = gql`
 `
 
 interface OnResponsePlayground {
-    exitCode: number
-    time: number
-    memory: number
+    status: string | null
+    exitCode: number | null
+    signal: number | null
+    time: number | null
+    memory: number | null
     stderr: string
     stdout: string
+    compileOutput: string | null
 }
 
 interface SubscriptionData {
@@ -64,11 +70,14 @@ const Playground: React.FC = () => {
     const [code, setCode] = useState('')
     const [stdin, setStdin] = useState('')
     const [result, setResult] = useState<OnResponsePlayground>({
+        status: null,
         exitCode: 0,
+        signal: null,
         time: 0,
         memory: 0,
         stdout: '',
         stderr: '',
+        compileOutput: null,
     })
     const [status, setStatus] = useState<Status>(Status.Normal)
     const onCodeEditorChange = useCallback(
@@ -108,11 +117,14 @@ const Playground: React.FC = () => {
         ),
         useCallback(({ onResponsePlayground }: SubscriptionData) => {
             setResult({
+                status: onResponsePlayground.status,
                 exitCode: onResponsePlayground.exitCode,
+                signal: onResponsePlayground.signal,
                 time: onResponsePlayground.time,
                 memory: onResponsePlayground.memory,
                 stdout: onResponsePlayground.stdout,
                 stderr: onResponsePlayground.stderr,
+                compileOutput: onResponsePlayground.compileOutput,
             })
             setStatus(Status.Received)
         }, []),
@@ -156,28 +168,58 @@ const Playground: React.FC = () => {
                 {status === Status.Received && (
                     <Table className="my-4" responsive bordered striped hover>
                         <tbody>
-                            <tr>
-                                <td className="text-nowrap">{t`exitCode`}</td>
-                                <td className="text-nowrap">
-                                    {result.exitCode}
-                                </td>
-                            </tr>
-                            <tr>
-                                <td className="text-nowrap">{t`time`}</td>
-                                <td className="text-nowrap">
-                                    {result.time} ms
-                                </td>
-                            </tr>
-                            <tr>
-                                <td className="text-nowrap">{t`memory`}</td>
-                                <td className="text-nowrap">
-                                    {result.memory} kb
-                                </td>
-                            </tr>
+                            {result.status !== null && (
+                                <tr>
+                                    <td className="text-nowrap">{t`status`}</td>
+                                    <td className="text-nowrap">
+                                        {result.status}
+                                    </td>
+                                </tr>
+                            )}
+                            {result.exitCode !== null && (
+                                <tr>
+                                    <td className="text-nowrap">
+                                        {t`exitCode`}
+                                    </td>
+                                    <td className="text-nowrap">
+                                        {result.exitCode}
+                                    </td>
+                                </tr>
+                            )}
+                            {result.signal !== null && (
+                                <tr>
+                                    <td className="text-nowrap">{t`signal`}</td>
+                                    <td className="text-nowrap">
+                                        {result.signal}
+                                    </td>
+                                </tr>
+                            )}
+                            {result.time !== null && (
+                                <tr>
+                                    <td className="text-nowrap">{t`time`}</td>
+                                    <td className="text-nowrap">
+                                        {result.time} ms
+                                    </td>
+                                </tr>
+                            )}
+                            {result.memory !== null && (
+                                <tr>
+                                    <td className="text-nowrap">{t`memory`}</td>
+                                    <td className="text-nowrap">
+                                        {result.memory} kb
+                                    </td>
+                                </tr>
+                            )}
                         </tbody>
                     </Table>
                 )}
                 <div>
+                    {result.compileOutput && (
+                        <>
+                            <Heading>{t`compileOutput`}</Heading>
+                            <Editor value={result.compileOutput} readOnly />
+                        </>
+                    )}
                     <Heading>{t`stdout`}</Heading>
                     <Editor value={result.stdout} readOnly />
                     <Heading>{t`stderr`}</Heading>
@@ -30,6 +30,16 @@ describe('getJudgeStatusFromTestcases', () => {
             progress: null,
         })
     })
+    test('should return status CLE and null progress', () => {
+        const result = getJudgeStatusFromTestcases(
+            SubmissionStatus.CLE,
+            testcases
+        )
+        expect(result).toEqual({
+            wholeStatus: JudgeStatus.CLE,
+            progress: null,
+        })
+    })
     test('should return status IE and null progress', () => {
         const result = getJudgeStatusFromTestcases(
             SubmissionStatus.IE,